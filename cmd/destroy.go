@@ -0,0 +1,39 @@
+// Copyright © 2018 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dansteen/terrarium/command"
+	"github.com/spf13/cobra"
+)
+
+// destroyCmd represents the destroy command
+var destroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Shut down this environment and remove its workspace",
+	Long: `Shut down the support services in this environment the same way shutdown
+does, and then remove their data dirs, logs, state files and binaries.
+Binaries shared with other projects through the binary cache are left alone.
+Destroy refuses to touch a directory that is not a terrarium workspace.`,
+	PreRun: bindFlags,
+	Run:    command.Destroy,
+}
+
+func init() {
+	rootCmd.AddCommand(destroyCmd)
+
+	destroyCmd.Flags().Bool("keep-data", false, "keep the consul and nomad data dirs, and the data and keys of a vault with persistent storage")
+	destroyCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+}
@@ -0,0 +1,41 @@
+// Copyright © 2018 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dansteen/terrarium/command"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how an application's data and secrets differ from what is loaded",
+	Long: `Compare the application's data and secrets files (infra/data.yml and
+infra/secrets.yml) with what is currently loaded into consul and vault, and
+print the keys that would be added, changed or removed.  Secret values are
+masked.  Nothing is changed.`,
+	PreRun: bindFlags,
+	Run:    command.Diff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("appPath", "a", "", "Path to the application directory to compare")
+	diffCmd.Flags().StringP("hashLabel", "l", "", "the version label to compare against (required when the consul key template uses {{hash_label}}, as the default does)")
+	diffCmd.Flags().StringP("env", "e", "", "environment whose data and secrets are merged over the defaults key by key (default is the env setting)")
+	diffCmd.MarkFlagRequired("appPath")
+}
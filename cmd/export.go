@@ -0,0 +1,48 @@
+// Copyright © 2018 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dansteen/terrarium/command"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export <appName>",
+	Short: "Export an application's loaded data and secrets in data file format",
+	Long: `Read the data loaded into consul for an application (under its consul key
+template, app/<appName>/<hashLabel>/ by default) and write it out in the format
+of infra/data.yml so that changes made while debugging can be copied back into
+the application.  Secrets under the application's vault prefix (the
+vault_path_template setting, or --secrets-prefix) are exported in the format
+of infra/secrets.yml when --secrets is set.  Without either of them the secrets
+can not be told apart from other applications' and are not exported.  Files are
+written to stdout unless --out is given.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: bindFlags,
+	Run:    command.Export,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringP("hashLabel", "l", "", "the version label the application was started with (required when the consul key template uses {{hash_label}}, as the default does)")
+	exportCmd.Flags().Bool("secrets", false, "also export the application's secrets from vault")
+	exportCmd.Flags().String("secrets-prefix", "", "the vault path the application's secrets are under (default is the vault_path_template setting)")
+	exportCmd.Flags().StringP("env", "e", "", "environment used to fill in the key and secret path templates (default is the env setting)")
+	exportCmd.Flags().StringP("appPath", "a", "", "path to the application directory, used to find its infra/consul_key.tmpl")
+	exportCmd.Flags().StringP("out", "o", "", "directory to write data.yml and secrets.yml to instead of stdout")
+}
@@ -16,7 +16,12 @@ package cmd
 
 import (
 	"github.com/dansteen/terrarium/command"
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	"github.com/dansteen/terrarium/service"
+	"github.com/dansteen/terrarium/vault"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // initCmd represents the init command
@@ -34,6 +39,17 @@ to quickly create a Cobra application.`,
 
 func init() {
 	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().Int("logLines", 20, "number of lines of a service's log to show when it fails to start")
+	viper.BindPFlag("logLines", initCmd.Flags().Lookup("logLines"))
+	initCmd.Flags().String("consul-version", "", "version of consul to run (default is the consul_version setting, the version already in the workspace, or "+consul.DefaultVersion+")")
+	initCmd.Flags().String("vault-version", "", "version of vault to run (default is the vault_version setting, the version already in the workspace, or "+vault.DefaultVersion+")")
+	initCmd.Flags().String("vault-storage", "", "where vault keeps its data: dev (in memory), file or consul (default is the vault_storage setting or the storage of an existing vault)")
+	initCmd.Flags().Bool("consul-acls", false, "run consul with default deny ACLs like production (default is the consul_acls setting or the ACL mode of an existing consul)")
+	initCmd.Flags().String("nomad-version", "", "version of nomad to run (default is the nomad_version setting, the version already in the workspace, or "+nomad.DefaultVersion+")")
+	initCmd.Flags().String("releases-url", "", "base url to download binaries from instead of "+service.DefaultReleasesURL+" (e.g. a local mirror)")
+	initCmd.Flags().String("consul-binary", "", "path to an installed consul binary to use instead of downloading one")
+	initCmd.Flags().String("vault-binary", "", "path to an installed vault binary to use instead of downloading one")
+	initCmd.Flags().String("nomad-binary", "", "path to an installed nomad binary to use instead of downloading one")
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,38 @@
+// Copyright © 2018 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dansteen/terrarium/command"
+	"github.com/spf13/cobra"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <consul|vault|nomad>",
+	Short: "Show the log file of a support service",
+	Long: `Show the end of the log file of one of the support services (consul, vault,
+or nomad) in this environment, and optionally keep following it as it grows.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: bindFlags,
+	Run:    command.Logs,
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().BoolP("follow", "f", false, "keep printing the log as it grows")
+	logsCmd.Flags().IntP("lines", "n", 20, "number of lines from the end of the log to show")
+}
@@ -17,6 +17,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"github.com/dansteen/terrarium/command"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/rs/zerolog"
@@ -36,9 +39,6 @@ var rootCmd = &cobra.Command{
 	environment, and start and stop applications inside of that environment.
 	It also manages the data for those applications in consul and vault.
 	`,
-	// we need to load in our project name
-	//Args:  cobra.ExactArgs(1),
-	//PersistentPreRun: loadProject,
 	Run: func(cmd *cobra.Command, args []string) { cmd.Help() },
 }
 
@@ -59,10 +59,18 @@ func init() {
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.terrarium.yaml)")
 	rootCmd.PersistentFlags().StringP("project", "p", "default", "the name of the project")
+	rootCmd.PersistentFlags().Int("stopTimeout", 10, "number of seconds to give a service to exit before escalating to a stronger signal")
+	viper.BindPFlag("stopTimeout", rootCmd.PersistentFlags().Lookup("stopTimeout"))
+	rootCmd.PersistentFlags().String("cache-dir", "", "directory binaries are cached in (default is $XDG_CACHE_HOME/terrarium/bin)")
+	viper.BindPFlag("cache_dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+	rootCmd.PersistentFlags().Bool("offline", false, "never download binaries and fail if they are not already cached")
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+
+	rootCmd.PersistentFlags().String("workspace-root", "", "directory project workspaces are created in (default is $XDG_DATA_HOME/terrarium)")
+	viper.BindPFlag("workspace_root", rootCmd.PersistentFlags().Lookup("workspace-root"))
 
-	// set the workdir from our project name
+	// the workdir is set from our project name once our flags and config have been loaded
 	viper.BindPFlag("project", rootCmd.PersistentFlags().Lookup("project"))
-	viper.Set("workspace", fmt.Sprintf("/tmp/terrarium_%s", rootCmd.PersistentFlags().Lookup("project").Value))
 
 	// we are running in the console, so we use the console logger
 	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
@@ -93,12 +101,43 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+
+	loadProject()
 }
 
-// loadProject will load up the name of the project you want to operate on
-func loadProject(cmd *cobra.Command, args []string) {
+// loadProject will find the project we are operating on and set the workspace for it.  Unless a project is named on the
+// command line it is discovered from a project marker in the current directory or one of its parents.
+func loadProject() {
+	if marker, found := command.FindProjectMarker("."); found {
+		file, err := os.Open(marker)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not read project file %s", marker)
+			os.Exit(1)
+		}
+		defer file.Close()
+
+		// project settings take precedence over the ones in our config file
+		viper.SetConfigType("yaml")
+		err = viper.MergeConfig(file)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not read project file %s", marker)
+			os.Exit(1)
+		}
+		// if the project file doesn't name the project we use the name of the directory it is in
+		viper.SetDefault("project", filepath.Base(filepath.Dir(marker)))
+		viper.Set("project_dir", filepath.Dir(marker))
+	}
+
+	root, err := command.WorkspaceRoot()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not find the workspace root")
+		os.Exit(1)
+	}
+	viper.Set("workspace", filepath.Join(root, viper.GetString("project")))
+}
 
-	// we are garanteed to have an args[0]
-	viper.Set("project", os.Args[1])
-	fmt.Println(viper.Get("project"))
+// bindFlags will bind the flags of a command into viper.  Several commands share flag names (e.g. hashLabel), so we
+// bind them when the command runs rather than in init(), where the last command to be set up would win.
+func bindFlags(cmd *cobra.Command, args []string) {
+	viper.BindPFlags(cmd.Flags())
 }
@@ -24,8 +24,48 @@ import (
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start an application in this environment",
-	Long:  `Start an application in the environment`,
-	Run:   command.StartApp,
+	Long: `Start an application in the environment.  The application's data and secrets
+(infra/data.yml and infra/secrets.yml) are loaded into consul and vault, and any
+nomad jobs (infra/*.nomad) are registered with nomad.
+
+Data files are organized in environment blocks, and the block for --env is
+merged over the default block.  Maps are merged key by key, so an environment
+only needs to list the keys it changes.  Lists and other values are replaced:
+
+  default:
+    db:
+      host: db.example.com
+      port: 5432
+  staging:
+    db:
+      host: staging-db.example.com
+
+Every top level key must be an environment block.  A secrets file without a
+default block is used as is.
+
+Values in the data and secrets files can refer to ${project}, ${app},
+${hash_label}, ${environment}, ${consul.address}, ${vault.address},
+${nomad.address} and environment variables (${env.NAME}).  Use $${ for a
+literal ${.  The !file tag loads the contents of a file and !include loads
+another yaml file.  Both are relative to the file they are used in.
+
+Each secret is written to vault with its value in a "value" field.  A map in
+the secrets file that is tagged !secret is written as a single secret instead,
+with a field for each of its keys:
+
+  db: !secret
+    username: app
+    password: ${env.DB_PASSWORD}
+
+Data is written to consul under the key template in the application's
+infra/consul_key.tmpl file or the consul_key_template setting, which can use
+{{app}}, {{env}}, {{project}}, {{hash_label}} and {{key}}.  The default is
+app/{{app}}/{{hash_label}}/{{key}}.
+
+Secrets are written relative to the path in the vault_path_template setting
+(e.g. secret/{{app}}/{{env}}), which can use {{app}}, {{env}}, {{project}}
+and {{hash_label}}.`,
+	Run: command.StartApp,
 }
 
 func init() {
@@ -36,11 +76,17 @@ func init() {
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	startCmd.PersistentFlags().StringP("appPath", "a", "", "Path to the application directory to add")
-	startCmd.PersistentFlags().StringP("hashLabel", "l", "", "arbitrary version label to use for this application")
+	startCmd.PersistentFlags().StringP("hashLabel", "l", "", "arbitrary version label to use for this application (required when the consul key template uses {{hash_label}}, as the default does)")
 	startCmd.MarkFlagRequired("appPath")
-	startCmd.MarkFlagRequired("hashLabel")
 	viper.BindPFlag("appPath", startCmd.PersistentFlags().Lookup("appPath"))
 	viper.BindPFlag("hashLabel", startCmd.PersistentFlags().Lookup("hashLabel"))
+	startCmd.Flags().Int("jobTimeout", 300, "number of seconds to wait for application jobs to become healthy")
+	viper.BindPFlag("jobTimeout", startCmd.Flags().Lookup("jobTimeout"))
+	startCmd.Flags().Bool("prune", false, "remove data and secrets that were loaded for the application before but are no longer in its data files (needs the vault_path_template setting)")
+	viper.BindPFlag("prune", startCmd.Flags().Lookup("prune"))
+	startCmd.Flags().Bool("dry-run", false, "show how the data and secrets would change without loading anything or running jobs")
+	viper.BindPFlag("dry-run", startCmd.Flags().Lookup("dry-run"))
+	startCmd.Flags().StringP("env", "e", "", "environment whose data and secrets are merged over the defaults key by key (default is the env setting)")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -0,0 +1,39 @@
+// Copyright © 2018 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dansteen/terrarium/command"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the health of the support services in this environment",
+	Long: `Report the pid, version, address, data dir, log file and health of each
+of the support services (consul, vault, and nomad) in this environment.
+Nothing is started or restarted.  The command exits non-zero if any service
+is unhealthy.`,
+	PreRun: bindFlags,
+	Run:    command.Status,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().Bool("json", false, "output the status as json")
+	statusCmd.Flags().Int("timeout", 5, "number of seconds to wait for each service to report healthy")
+}
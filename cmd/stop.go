@@ -0,0 +1,41 @@
+// Copyright © 2018 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/dansteen/terrarium/command"
+	"github.com/spf13/cobra"
+)
+
+// stopCmd represents the stop command
+var stopCmd = &cobra.Command{
+	Use:   "stop <appPath>",
+	Short: "Stop an application in this environment",
+	Long: `Stop an application in the environment.  The application's nomad jobs are
+deregistered and the data that was loaded into consul (under the application's
+consul key template for the hash label) is removed.  Secrets loaded into vault are only removed when --secrets is set.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: bindFlags,
+	Run:    command.StopApp,
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+
+	stopCmd.Flags().StringP("hashLabel", "l", "", "the version label the application was started with (required when the consul key template uses {{hash_label}}, as the default does)")
+	stopCmd.Flags().Bool("purge", false, "purge the application's jobs from nomad rather than just stopping them")
+	stopCmd.Flags().Bool("secrets", false, "also remove the application's secrets from vault")
+	stopCmd.Flags().StringP("env", "e", "", "environment the application was started with (default is the env setting)")
+}
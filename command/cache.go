@@ -0,0 +1,87 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	terrarium "github.com/dansteen/terrarium/service"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// binaryCache will return the shared binary cache
+func binaryCache() (*terrarium.Cache, error) {
+	dir := viper.GetString("cache_dir")
+	if dir == "" {
+		var err error
+		dir, err = terrarium.DefaultCacheDir()
+		if err != nil {
+			log.Error().Err(err).Msg("Could not find the binary cache directory")
+			return nil, err
+		}
+	}
+	return &terrarium.Cache{Dir: dir, Offline: viper.GetBool("offline")}, nil
+}
+
+// CacheList will list the binaries in the shared binary cache
+func CacheList(cmd *cobra.Command, args []string) {
+	cache, err := binaryCache()
+	if err != nil {
+		os.Exit(1)
+	}
+	entries, err := cache.Entries()
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not read the binary cache at %s", cache.Dir)
+		os.Exit(1)
+	}
+	inUse := cachedBinariesInUse()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tVERSION\tPLATFORM\tSIZE\tIN USE\tPATH")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%.1fM\t%t\t%s\n", entry.Name, entry.Version, entry.Platform, float64(entry.Size)/(1024*1024), inUse[entry.Path], entry.Path)
+	}
+	writer.Flush()
+}
+
+// CachePrune will remove the binaries from the shared binary cache that are not used by any workspace
+func CachePrune(cmd *cobra.Command, args []string) {
+	cache, err := binaryCache()
+	if err != nil {
+		os.Exit(1)
+	}
+	entries, err := cache.Entries()
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not read the binary cache at %s", cache.Dir)
+		os.Exit(1)
+	}
+	inUse := cachedBinariesInUse()
+
+	for _, entry := range entries {
+		if inUse[entry.Path] && !viper.GetBool("all") {
+			continue
+		}
+		err = cache.Remove(entry)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not remove %s %s from the cache", entry.Name, entry.Version)
+			os.Exit(1)
+		}
+		log.Info().Msgf("Removed %s %s (%s) from the cache", entry.Name, entry.Version, entry.Platform)
+	}
+}
+
+// cachedBinariesInUse will return the set of cached binaries that are linked from a workspace
+func cachedBinariesInUse() map[string]bool {
+	inUse := make(map[string]bool)
+	for _, workspace := range knownWorkspaces() {
+		for _, name := range []string{"consul", "vault", "nomad"} {
+			if target, err := os.Readlink(filepath.Join(workspace, name)); err == nil {
+				inUse[target] = true
+			}
+		}
+	}
+	return inUse
+}
@@ -0,0 +1,200 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	terrarium "github.com/dansteen/terrarium/service"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// projectKey will return the viper key for a setting, preferring the project specific version of the setting
+// (projects.<project>.<key>) if one has been set in the config file.
+func projectKey(key string) string {
+	scoped := fmt.Sprintf("projects.%s.%s", viper.GetString("project"), key)
+	if viper.IsSet(scoped) {
+		return scoped
+	}
+	return key
+}
+
+// stringSetting will return the value of a string flag if it was given on the command line, and otherwise the value of the
+// (project specific) setting key from the terrarium config
+func stringSetting(cmd *cobra.Command, flag, key string) string {
+	if cmd.Flags().Changed(flag) {
+		value, _ := cmd.Flags().GetString(flag)
+		return value
+	}
+	return viper.GetString(projectKey(key))
+}
+
+// boolSetting will return the value of a bool flag if it was given on the command line, and otherwise the value of the
+// (project specific) setting key from the terrarium config.  It also returns false if neither of them were set.
+func boolSetting(cmd *cobra.Command, flag, key string) (bool, bool) {
+	if cmd.Flags().Changed(flag) {
+		value, _ := cmd.Flags().GetBool(flag)
+		return value, true
+	}
+	if viper.IsSet(projectKey(key)) {
+		return viper.GetBool(projectKey(key)), true
+	}
+	return false, false
+}
+
+// vaultMounts will return the secret backends that a project wants mounted in vault from the (project specific)
+// vault_mounts setting.  An empty list means the project is happy with the defaults.
+func vaultMounts() ([]vault.Mount, error) {
+	mounts := []vault.Mount{}
+	err := viper.UnmarshalKey(projectKey("vault_mounts"), &mounts)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not read the vault_mounts setting")
+		return mounts, err
+	}
+	for _, mount := range mounts {
+		if mount.Path == "" {
+			err = errors.New("every vault mount needs a path")
+			log.Error().Err(err).Msg("Could not read the vault_mounts setting")
+			return mounts, err
+		}
+	}
+	return mounts, nil
+}
+
+// secretPath will render the (project specific) vault_path_template setting into the path in vault that an
+// application's secrets are loaded under.  The template can use {{app}}, {{env}}, {{project}} and {{hash_label}}, e.g.
+// secret/{{app}}/{{env}}.  It returns an empty path if there is no template.
+func secretPath(vars map[string]string) (string, error) {
+	setting := viper.GetString(projectKey("vault_path_template"))
+	if setting == "" {
+		return "", nil
+	}
+	rendered, err := renderPath("vault_path_template", setting, vars)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not render the vault_path_template setting")
+		return "", err
+	}
+	return strings.Trim(path.Clean("/"+rendered), "/"), nil
+}
+
+// defaultKeyTemplate is the layout of the keys that an application's data is loaded into consul with if neither the
+// application nor the project sets one.  It follows
+// https://github.com/traitify/ops_scripts/blob/master/CONSUL_ORGANIZATION.md#app
+const defaultKeyTemplate = "app/{{app}}/{{hash_label}}/{{key}}"
+
+// keyTemplateFile is where an application can set the layout of its keys in consul
+const keyTemplateFile = "infra/consul_key.tmpl"
+
+// keyPrefix will render the layout of the keys that an application's data is loaded into consul with, and return the
+// prefix that all of its keys are under.  The layout comes from the infra/consul_key.tmpl file of the application, the
+// (project specific) consul_key_template setting, or defaultKeyTemplate, in that order.  It can use {{app}}, {{env}},
+// {{project}} and {{hash_label}}, and {{key}} must be at the end of it if it is used.  appPath can be empty if the
+// application isn't available.
+func keyPrefix(appPath string, vars map[string]string) (string, error) {
+	name := "consul_key_template"
+	layout := viper.GetString(projectKey("consul_key_template"))
+	if appPath != "" {
+		templatePath := filepath.Join(appPath, keyTemplateFile)
+		if content, err := ioutil.ReadFile(templatePath); err == nil {
+			name, layout = templatePath, strings.TrimSpace(string(content))
+		} else if !os.IsNotExist(err) {
+			log.Error().Err(err).Msgf("Error reading consul key template at %s.", templatePath)
+			return "", err
+		}
+	}
+	if layout == "" {
+		layout = defaultKeyTemplate
+	}
+
+	// we render the template with a placeholder for the key so that we can find where the keys go
+	const placeholder = "\x00key"
+	withKey := map[string]string{"key": placeholder}
+	for key, value := range vars {
+		withKey[key] = value
+	}
+	rendered, err := renderPath(name, layout, withKey)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not render the consul key template %s", name)
+		return "", err
+	}
+	if index := strings.Index(rendered, placeholder); index >= 0 {
+		if rendered[index+len(placeholder):] != "" {
+			err = fmt.Errorf("{{key}} must be at the end of the consul key template %s", name)
+			log.Error().Err(err).Msg("Could not render the consul key template")
+			return "", err
+		}
+		rendered = rendered[:index]
+	}
+	prefix := strings.Trim(path.Clean("/"+rendered), "/")
+	if prefix == "" {
+		err = fmt.Errorf("the consul key template %s would put the application's keys at the root of consul", name)
+		log.Error().Err(err).Msg("Could not render the consul key template")
+		return "", err
+	}
+	return prefix + "/", nil
+}
+
+// renderPath will render a template that lays out a path in consul or vault.  Templates refer to values with functions
+// (e.g. {{app}}), which take them from vars.  The hash label is optional, so it is an error for a template to use it
+// when we don't have one.
+func renderPath(name, text string, vars map[string]string) (string, error) {
+	funcs := template.FuncMap{
+		"hash_label": func() (string, error) {
+			if vars["hash_label"] == "" {
+				return "", errors.New("{{hash_label}} is used but no hash label was given (use --hashLabel)")
+			}
+			return vars["hash_label"], nil
+		},
+	}
+	for function, key := range map[string]string{"app": "app", "env": "environment", "project": "project", "key": "key"} {
+		value := vars[key]
+		funcs[function] = func() string { return value }
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, nil)
+	return rendered.String(), err
+}
+
+// loadConfigTemplate will replace the built in service config template of a service with a user supplied one if there is
+// one.  In order of preference templates come from a terrarium.d/<service>.hcl.tmpl file in the workspace or the project
+// directory, or the templates.<service> setting in the terrarium config.
+func loadConfigTemplate(service terrarium.SupportService) error {
+	dirs := []string{service.Workspace()}
+	if projectDir := viper.GetString("project_dir"); projectDir != "" {
+		dirs = append(dirs, projectDir)
+	}
+	for _, dir := range dirs {
+		templatePath := filepath.Join(dir, "terrarium.d", service.Name()+".hcl.tmpl")
+		if _, err := os.Stat(templatePath); err != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(templatePath)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error reading config template at %s.", templatePath)
+			return err
+		}
+		log.Info().Msgf("Using %s config template %s", service.Name(), templatePath)
+		service.SetServiceConfig(string(content))
+		return nil
+	}
+
+	key := projectKey("templates." + service.Name())
+	if viper.IsSet(key) {
+		log.Info().Msgf("Using %s config template from %s", service.Name(), key)
+		service.SetServiceConfig(viper.GetString(key))
+	}
+	return nil
+}
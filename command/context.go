@@ -0,0 +1,23 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// interruptContext will return a context that is canceled when the user hits Ctrl-C so that long waits can be aborted
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,138 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	terrarium "github.com/dansteen/terrarium/service"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Destroy will stop all of the support services in this terrarium environment and remove its workspace
+func Destroy(cmd *cobra.Command, args []string) {
+	// grab our workspace
+	workspace := viper.GetString("workspace")
+
+	// make sure we are about to delete what we think we are
+	if _, err := os.Stat(workspace); err != nil {
+		log.Error().Err(err).Msgf("Could not find workspace %s: ", workspace)
+		os.Exit(1)
+	}
+	if !isWorkspace(workspace) {
+		log.Error().Msgf("Refusing to destroy %s: it is not a terrarium workspace (%s is missing)", workspace, workspaceMarker)
+		os.Exit(1)
+	}
+
+	if !viper.GetBool("yes") && !confirm(fmt.Sprintf("Destroy project %s and remove %s?", viper.GetString("project"), workspace)) {
+		log.Info().Msg("Aborted")
+		return
+	}
+
+	// shut everything down the same way shutdown does
+	err := stopServices(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// and then remove what each service left behind
+	for _, name := range []string{"nomad", "vault", "consul"} {
+		// services that were never set up have nothing to remove
+		if _, err := os.Stat(filepath.Join(workspace, name+".yml")); err != nil {
+			continue
+		}
+		service, err := getService(workspace, name)
+		if err != nil {
+			os.Exit(1)
+		}
+		keepData := viper.GetBool("keep-data")
+		keepState := false
+		// vault only keeps its data with persistent storage, and that data can't be read without the unseal keys in its
+		// state file
+		if vaultService, ok := service.(*vault.Service); ok {
+			keepData = keepData && vaultService.Storage != "" && vaultService.Storage != vault.StorageDev
+			keepState = keepData
+		}
+		err = removeService(service, keepData, keepState)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+
+	// finally remove the workspace itself if there is nothing left in it
+	os.Remove(filepath.Join(workspace, workspaceMarker))
+	err = os.Remove(workspace)
+	if err != nil {
+		// put the marker back so we can find the workspace again
+		markWorkspace(workspace)
+		log.Warn().Msgf("Left %s in place since it still has data in it", workspace)
+		return
+	}
+	log.Info().Msgf("Destroyed project %s", viper.GetString("project"))
+}
+
+// removeService will remove the data dir, log file, state file and binary of a stopped service.  Binaries that are
+// links to the shared cache or an installed binary are unlinked but the binary itself is left alone.
+func removeService(service terrarium.SupportService, keepData, keepState bool) error {
+	generic := service.Base()
+	if generic.Running() {
+		err := fmt.Errorf("%s (pid %d) is still running", strings.Title(service.Name()), generic.Pid)
+		log.Error().Err(err).Msgf("Could not destroy %s", strings.Title(service.Name()))
+		return err
+	}
+
+	paths := []string{
+		generic.Logfile,
+		filepath.Join(service.Workspace(), service.Name()),
+	}
+	if !keepState {
+		paths = append(paths, filepath.Join(service.Workspace(), service.Name()+".yml"))
+	}
+	if !keepData {
+		paths = append(paths, generic.Datadir)
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		// we never remove anything that lives outside of the workspace
+		if !insideDir(service.Workspace(), path) {
+			err := fmt.Errorf("%s is outside of the workspace %s", path, service.Workspace())
+			log.Error().Err(err).Msgf("Refusing to remove %s", path)
+			return err
+		}
+		// os.RemoveAll does not follow symlinks, so links to shared binaries are unlinked rather than removed
+		err := os.RemoveAll(path)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not remove %s", path)
+			return err
+		}
+	}
+	log.Info().Msgf("Removed %s", strings.Title(service.Name()))
+	return nil
+}
+
+// insideDir will return true if path is inside of dir
+func insideDir(dir, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return relative != "." && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// confirm will ask the user a yes or no question
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	"github.com/dansteen/terrarium/utility"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the value we show in place of a secret
+const maskedValue = "********"
+
+// Diff will show how the data and secrets loaded for an application differ from the ones in its data files
+func Diff(cmd *cobra.Command, args []string) {
+	// grab our workspace
+	workspace := viper.GetString("workspace")
+	appPath := viper.GetString("appPath")
+	hashLabel := viper.GetString("hashLabel")
+
+	// get our service information
+	consulService, err := consul.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+	vaultService, err := vault.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+	nomadService, err := nomad.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// get the name of this application
+	appName, err := GetAppName(appPath)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	options, err := dataOptions(cmd, appPath, appName, hashLabel, consulService, vaultService, nomadService)
+	if err != nil {
+		os.Exit(1)
+	}
+	err = showDiff(appPath, options, consulService, vaultService)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// showDiff will print the differences between the data files of an application and what is loaded in consul and vault
+func showDiff(appPath string, options utility.DataOptions, consulService *consul.Service, vaultService *vault.Service) error {
+	changes, err := consulService.Diff(filepath.Join(appPath, "infra/data.yml"), options)
+	if err != nil {
+		return err
+	}
+	printChanges(fmt.Sprintf("consul (%s)", options.KeyPrefix), changes, false)
+
+	changes, err = vaultService.Diff(filepath.Join(appPath, "infra/secrets.yml"), options)
+	if err != nil {
+		return err
+	}
+	printChanges("vault", changes, true)
+	return nil
+}
+
+// printChanges will print a list of changes.  If mask is set the values are hidden.
+func printChanges(title string, changes []utility.Change, mask bool) {
+	fmt.Printf("%s: %d added, %d changed, %d removed\n", title, countChanges(changes, utility.Added), countChanges(changes, utility.Changed), countChanges(changes, utility.Removed))
+	for _, change := range changes {
+		oldValue, newValue := change.Old, change.New
+		if mask {
+			oldValue, newValue = maskedValue, maskedValue
+		}
+		switch change.Action {
+		case utility.Added:
+			fmt.Printf("  + %s = %s\n", change.Key, newValue)
+		case utility.Changed:
+			fmt.Printf("  ~ %s = %s -> %s\n", change.Key, oldValue, newValue)
+		case utility.Removed:
+			fmt.Printf("  - %s = %s\n", change.Key, oldValue)
+		}
+	}
+}
+
+// countChanges will count the changes with a particular action
+func countChanges(changes []utility.Change, action string) int {
+	count := 0
+	for _, change := range changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
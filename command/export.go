@@ -0,0 +1,120 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/utility"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Export will write the data (and optionally the secrets) that are loaded for an application back out in the format of
+// its data files
+func Export(cmd *cobra.Command, args []string) {
+	// grab our workspace
+	workspace := viper.GetString("workspace")
+	appName := args[0]
+	hashLabel := viper.GetString("hashLabel")
+	outDir := viper.GetString("out")
+
+	consulService, err := consul.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+	// the key and secret layouts can use any of these
+	vars := map[string]string{
+		"project":     viper.GetString("project"),
+		"app":         appName,
+		"hash_label":  hashLabel,
+		"environment": stringSetting(cmd, "env", "env"),
+	}
+	prefix, err := keyPrefix(viper.GetString("appPath"), vars)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// secrets files are relative to the path that the vault_path_template setting puts the app's secrets under
+	loadedPath, err := secretPath(vars)
+	if err != nil {
+		os.Exit(1)
+	}
+	secretsPrefix := viper.GetString("secrets-prefix")
+	if secretsPrefix == "" {
+		secretsPrefix = loadedPath
+	}
+	// without a template secrets are loaded to the paths that are written in the secrets file, so there is no way to
+	// know which of them belong to the app
+	if viper.GetBool("secrets") && secretsPrefix == "" {
+		err = fmt.Errorf("no vault_path_template setting")
+		log.Error().Err(err).Msg("Can not tell where the application's secrets are, set --secrets-prefix")
+		os.Exit(1)
+	}
+
+	records, err := consulService.Export(prefix)
+	if err != nil {
+		os.Exit(1)
+	}
+	// data files keep their values under an environment, and everything we load comes from the default one
+	data, err := utility.Marshal(map[string]interface{}{"default": utility.Unflatten(records)})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not generate data file")
+		os.Exit(1)
+	}
+	err = writeExport(outDir, "data.yml", data, 0644)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// secrets are only exported if asked so that they don't end up on the screen by accident
+	if !viper.GetBool("secrets") {
+		return
+	}
+	vaultService, err := vault.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+	secrets, err := vaultService.Export(secretsPrefix)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not export secrets under %s", secretsPrefix)
+		os.Exit(1)
+	}
+	if loadedPath != "" {
+		relative := map[string]string{}
+		for key, value := range secrets {
+			relative[strings.TrimPrefix(key, loadedPath+"/")] = value
+		}
+		secrets = relative
+	}
+	data, err = utility.Marshal(utility.Unflatten(secrets))
+	if err != nil {
+		log.Error().Err(err).Msg("Could not generate secrets file")
+		os.Exit(1)
+	}
+	err = writeExport(outDir, "secrets.yml", data, 0600)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// writeExport will write an exported file to outDir, or to stdout if outDir is empty
+func writeExport(outDir, name string, data []byte, mode os.FileMode) error {
+	if outDir == "" {
+		fmt.Printf("# %s\n%s", name, data)
+		return nil
+	}
+	path := filepath.Join(outDir, name)
+	err := ioutil.WriteFile(path, data, mode)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not write %s", path)
+		return err
+	}
+	log.Info().Msgf("Exported %s", path)
+	return nil
+}
@@ -1,13 +1,15 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/dansteen/terrarium/consul"
 	"github.com/dansteen/terrarium/nomad"
-	"github.com/dansteen/terrarium/service"
+	terrarium "github.com/dansteen/terrarium/service"
 	"github.com/dansteen/terrarium/vault"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -21,74 +23,169 @@ func InitEnv(cmd *cobra.Command, args []string) {
 	// check to see if it exists
 	if _, err := os.Stat(fmt.Sprintf("%s", workspace)); err == nil {
 		log.Info().Msg("Found existing project. Health Checking.")
+		// workspaces created by older versions of terrarium are not marked
+		if !isWorkspace(workspace) {
+			err = markWorkspace(workspace)
+			if err != nil {
+				os.Exit(1)
+			}
+		}
 	} else {
 		log.Info().Msgf("Creating project at %s.", workspace)
-		err := os.Mkdir(workspace, 0755)
+		err := os.MkdirAll(workspace, 0755)
 		if err != nil {
 			log.Error().Err(err).Msgf("Could not create project")
 			os.Exit(1)
 		}
+		err = markWorkspace(workspace)
+		if err != nil {
+			os.Exit(1)
+		}
 	}
 
+	// stopped environments keep their ports, so we make sure we don't give them to our services
+	for _, other := range knownWorkspaces() {
+		if filepath.Clean(other) != filepath.Clean(workspace) {
+			terrarium.ReserveWorkspacePorts(other)
+		}
+	}
+
+	// let the user abort our health checks
+	ctx, cancel := interruptContext()
+	defer cancel()
+
 	// spin up consul
-	consulInstance, err := consul.NewService(workspace)
+	consulInstance, err := consul.NewService(workspace, stringSetting(cmd, "consul-version", "consul_version"))
 	if err != nil {
 		os.Exit(1)
 	}
-	err = startService(consulInstance)
+	// ACLs stay the way they are unless we are asked to change them
+	if acls, set := boolSetting(cmd, "consul-acls", "consul_acls"); set {
+		err = consulInstance.SetACLs(acls)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+	err = configureBinary(cmd, consulInstance)
+	if err != nil {
+		os.Exit(1)
+	}
+	err = startService(ctx, consulInstance)
 	if err != nil {
 		os.Exit(1)
 	}
 
 	// spin up vault
-	vaultInstance, err := vault.NewService(workspace)
+	vaultInstance, err := vault.NewService(workspace, stringSetting(cmd, "vault-version", "vault_version"), stringSetting(cmd, "vault-storage", "vault_storage"), consulInstance.Address, consulInstance.ACLToken)
 	if err != nil {
 		os.Exit(1)
 	}
-	err = startService(vaultInstance)
+	err = configureBinary(cmd, vaultInstance)
 	if err != nil {
 		os.Exit(1)
 	}
-	// configure our vault backents
+	err = startService(ctx, vaultInstance)
+	if err != nil {
+		os.Exit(1)
+	}
+	// configure our vault backends and record them so we know how to load secrets into them
+	mounts, err := vaultMounts()
+	if err != nil {
+		os.Exit(1)
+	}
+	vaultInstance.SetMounts(mounts)
 	err = vaultInstance.ConfigureBackends()
 	if err != nil {
 		os.Exit(1)
 	}
+	err = vaultInstance.Write()
+	if err != nil {
+		os.Exit(1)
+	}
+	// and give nomad its own token rather than our root token
+	err = vaultInstance.ConfigureNomad()
+	if err != nil {
+		os.Exit(1)
+	}
 
 	// spin up nomad
-	nomadInstance, err := nomad.NewService(workspace, consulInstance.Address, vaultInstance.Address, vaultInstance.RootToken)
+	nomadInstance, err := nomad.NewService(workspace, stringSetting(cmd, "nomad-version", "nomad_version"), consulInstance.Address, consulInstance.ACLToken, vaultInstance.Address, vaultInstance.NomadToken)
+	if err != nil {
+		os.Exit(1)
+	}
+	err = configureBinary(cmd, nomadInstance)
 	if err != nil {
 		os.Exit(1)
 	}
-	err = startService(nomadInstance)
+	err = startService(ctx, nomadInstance)
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// configureBinary will set up where and how a service gets its binary from
+func configureBinary(cmd *cobra.Command, service terrarium.SupportService) error {
+	cache, err := binaryCache()
+	if err != nil {
+		return err
+	}
+	service.SetCache(cache)
+	if releasesURL := stringSetting(cmd, "releases-url", "releases_url"); releasesURL != "" {
+		service.SetReleasesURL(releasesURL)
+	}
+	service.SetSigningKey(viper.GetString(projectKey("signing_key")))
+	service.SetBinaryPath(stringSetting(cmd, service.Name()+"-binary", service.Name()+"_binary"))
+	return nil
+}
+
 // startService will start up a support service or restart it if its unhealthy
-func startService(service service.SupportService) error {
+func startService(ctx context.Context, service terrarium.SupportService) error {
 
-	// first see if we have an existing config in the services workspace
-	read, err := service.Read()
+	// first see if we have an existing instance in the services workspace (our services read it in when they are created)
+	read := service.Exists()
+
+	// pick up the config template so we can tell if the config of an existing instance has changed
+	err := loadConfigTemplate(service)
 	if err != nil {
 		return err
 	}
+	if read && service.RestartReason() == "" && service.ConfigChanged() {
+		service.RequireRestart("config has changed")
+	}
+
+	// if the service needs to be restarted to pick up a change (e.g. a new version) we stop it before we replace anything
+	if read && service.RestartReason() != "" {
+		log.Info().Msgf("%s %s. Restarting...", strings.Title(service.Name()), service.RestartReason())
+		err := stopService(service)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = service.Init()
 	if err != nil {
 		os.Exit(1)
 	}
 	// if there is already an instance in this workspace
-	if read {
+	if read && service.RestartReason() == "" {
 		log.Info().Msgf("Existing %s Instance found. Checking...", strings.Title(service.Name()))
-		// check to see if its healthy
-		healthy, _ := service.Healthy()
+		// some services need to be set up again before they are usable (e.g. unsealing vault), and then we check to see
+		// if its healthy
+		healthy := false
+		err := bootstrapService(ctx, service)
+		if err == nil {
+			healthy, err = service.Healthy(ctx)
+		}
 		// if we are healthy we return
 		if healthy {
 			log.Info().Msgf("%s is Healthy.", strings.Title(service.Name()))
 			return nil
 		}
+		// if the user gave up on us we don't go restarting things
+		if terrarium.Reason(err) == terrarium.ReasonCanceled {
+			log.Error().Err(err).Msg("Aborted")
+			return err
+		}
 
 		// depending on the error returned we do things a bit differently
 		// if the process does not exist
@@ -97,38 +194,74 @@ func startService(service service.SupportService) error {
 		//	} else {
 		log.Warn().Msgf("%s is not healthy. Restarting...", strings.Title(service.Name()))
 		//}
-		service.Stop()
+		err = stopService(service)
+		if err != nil {
+			return err
+		}
 	}
 
-	// regardless we issue a start
-	err = service.Start()
+	// write the service config first so the service picks up the ports we have assigned it
+	err = service.WriteServiceConfig()
 	if err != nil {
 		return err
 	}
 
-	// then, we write the service config
-	err = service.WriteServiceConfig()
+	// regardless we issue a start
+	err = service.Start()
 	if err != nil {
 		return err
 	}
-	// and and our config to make sure we have all the information we need
+
+	// and record it along with everything else the service keeps in its state file
 	err = service.Write()
 	if err != nil {
+		// stop the service so we dont leave running processes around
+		stopService(service)
 		return err
 	}
 
-	// then we make sure things are healthy
+	// then we make sure things are set up and healthy
 	log.Info().Msgf("Waiting %d seconds for %s to come up", service.HealthyTimeout(), strings.Title(service.Name()))
-	healthy, err := service.Healthy()
+	healthy := false
+	err = bootstrapService(ctx, service)
+	if err == nil {
+		healthy, err = service.Healthy(ctx)
+	}
 	if err != nil || !healthy {
 		// if we had an error we stop the process
-		if err.Error() == "os: process not initialized" {
+		if terrarium.Reason(err) == terrarium.ReasonProcessExited {
 			log.Error().Err(err).Msgf("Could not start %s", strings.Title(service.Name()))
 		} else {
 			log.Error().Err(err).Msgf("%s not Healthy", strings.Title(service.Name()))
 		}
-		service.Stop()
+		// the real cause of the problem is usually in the service's log
+		if terrarium.Reason(err) != terrarium.ReasonCanceled {
+			printLogTail(service, viper.GetInt("logLines"))
+		}
+		stopService(service)
 		return err
 	}
 	return nil
 }
+
+// bootstrapService will run any setup that a service needs each time it starts
+func bootstrapService(ctx context.Context, service terrarium.SupportService) error {
+	if bootstrapper, ok := service.(terrarium.Bootstrapper); ok {
+		return bootstrapper.Bootstrap(ctx)
+	}
+	return nil
+}
+
+// printLogTail will print the last lines of a service's log file so the user can see why it failed
+func printLogTail(service terrarium.SupportService, count int) {
+	lines, err := service.TailLog(count)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Could not read the %s log file", strings.Title(service.Name()))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "--- last %d lines of the %s log ---\n", len(lines), strings.Title(service.Name()))
+	for _, line := range lines {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	fmt.Fprintln(os.Stderr, "---")
+}
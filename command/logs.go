@@ -0,0 +1,88 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	terrarium "github.com/dansteen/terrarium/service"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Logs will show the log file of one of the support services in this environment
+func Logs(cmd *cobra.Command, args []string) {
+	// grab our workspace
+	workspace := viper.GetString("workspace")
+
+	service, err := getService(workspace, args[0])
+	if err != nil {
+		os.Exit(1)
+	}
+
+	count := viper.GetInt("lines")
+	if count < 0 {
+		log.Error().Msgf("--lines must be 0 or more, not %d", count)
+		os.Exit(1)
+	}
+
+	// first show the end of the log
+	lines, err := service.TailLog(count)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not read the %s log file", strings.Title(service.Name()))
+		os.Exit(1)
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+	// and then keep going if we were asked to
+	if viper.GetBool("follow") {
+		done := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		go func() {
+			<-signals
+			close(done)
+		}()
+		err = service.FollowLog(os.Stdout, done)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not follow the %s log file", strings.Title(service.Name()))
+			os.Exit(1)
+		}
+	}
+}
+
+// getService will get an existing support service in a workspace by name
+func getService(workspace, name string) (terrarium.SupportService, error) {
+	var service terrarium.SupportService
+	var err error
+	switch strings.ToLower(name) {
+	case "consul":
+		service, err = consul.GetService(workspace)
+	case "vault":
+		service, err = vault.GetService(workspace)
+	case "nomad":
+		service, err = nomad.GetService(workspace)
+	default:
+		err = fmt.Errorf("unknown service %s: must be one of consul, vault or nomad", name)
+		log.Error().Err(err).Msg("Could not find service")
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	// GetService does not consider a missing service to be an error, but we do
+	if found, _ := service.Read(); !found {
+		err = errors.New("not found")
+		log.Error().Err(err).Msgf("Could not find %s in workspace %s", name, workspace)
+		return nil, err
+	}
+	return service, nil
+}
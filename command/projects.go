@@ -0,0 +1,61 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	terrarium "github.com/dansteen/terrarium/service"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/spf13/cobra"
+)
+
+// Projects will list the projects on this machine along with their workspaces and whether their services are running
+func Projects(cmd *cobra.Command, args []string) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROJECT\tWORKSPACE\tCONSUL\tVAULT\tNOMAD")
+	for _, workspace := range knownWorkspaces() {
+		project, err := workspaceProject(workspace)
+		if err != nil {
+			project = filepath.Base(workspace)
+		}
+		fmt.Fprintf(writer, "%s\t%s", project, workspace)
+		for _, name := range []string{"consul", "vault", "nomad"} {
+			fmt.Fprintf(writer, "\t%s", serviceState(workspace, name))
+		}
+		fmt.Fprintln(writer)
+	}
+	writer.Flush()
+}
+
+// serviceState will return whether a service in a workspace is running, stopped, or has never been set up.  This only
+// checks the process, so it is quick and does not log anything for missing services.
+func serviceState(workspace, name string) string {
+	probe := terrarium.Generic{}
+	probe.SetName(name)
+	probe.SetWorkspace(workspace)
+	if !probe.Exists() {
+		return "-"
+	}
+	// each service keeps its own state file layout, so we read it with the service itself
+	var service terrarium.SupportService
+	var err error
+	switch name {
+	case "consul":
+		service, err = consul.GetService(workspace)
+	case "vault":
+		service, err = vault.GetService(workspace)
+	case "nomad":
+		service, err = nomad.GetService(workspace)
+	}
+	if err != nil || service == nil {
+		return "-"
+	}
+	if service.Base().Running() {
+		return "running"
+	}
+	return "stopped"
+}
@@ -6,13 +6,14 @@ import (
 
 	"github.com/dansteen/terrarium/consul"
 	"github.com/dansteen/terrarium/nomad"
+	terrarium "github.com/dansteen/terrarium/service"
 	"github.com/dansteen/terrarium/vault"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-// Shutdown will initialize this terrarium environment by creating the workspace and starting the support applications
+// Shutdown will stop the support applications for this terrarium environment
 func Shutdown(cmd *cobra.Command, args []string) {
 	// grab our workspace
 	workspace := viper.GetString("workspace")
@@ -23,26 +24,50 @@ func Shutdown(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	// spin down our applications
-	consulInstance, err := consul.GetService(workspace)
+	err := stopServices(workspace)
 	if err != nil {
-		log.Error().Err(err).Msgf("Unable to stop %s:", consulInstance.Name())
-	} else {
-		consulInstance.Stop()
+		os.Exit(1)
+	}
+}
+
+// stopServices will stop all of the support services in a workspace.  Services are stopped in the reverse of the order
+// they depend on each other (nomad, then vault, then consul).  It will attempt to stop all of the services even if one of
+// them fails, and will return the last error it encountered.
+func stopServices(workspace string) error {
+	var lastErr error
+
+	nomadInstance, err := nomad.GetService(workspace)
+	if err == nil {
+		err = stopService(nomadInstance)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Unable to stop %s:", nomadInstance.Name())
+		lastErr = err
 	}
 
 	vaultInstance, err := vault.GetService(workspace)
+	if err == nil {
+		err = stopService(vaultInstance)
+	}
 	if err != nil {
 		log.Error().Err(err).Msgf("Unable to stop %s:", vaultInstance.Name())
-	} else {
-		vaultInstance.Stop()
+		lastErr = err
 	}
 
-	nomadInstance, err := nomad.GetService(workspace)
+	consulInstance, err := consul.GetService(workspace)
+	if err == nil {
+		err = stopService(consulInstance)
+	}
 	if err != nil {
-		log.Error().Err(err).Msgf("Unable to stop %s:", nomadInstance.Name())
-	} else {
-		nomadInstance.Stop()
+		log.Error().Err(err).Msgf("Unable to stop %s:", consulInstance.Name())
+		lastErr = err
 	}
 
+	return lastErr
+}
+
+// stopService will stop a single support service using the configured grace period
+func stopService(service terrarium.SupportService) error {
+	service.SetStopTimeout(viper.GetInt("stopTimeout"))
+	return service.Stop()
 }
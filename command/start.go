@@ -5,9 +5,13 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	"github.com/dansteen/terrarium/utility"
 	"github.com/dansteen/terrarium/vault"
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -33,22 +37,105 @@ func StartApp(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// and our nomad service information
+	nomadService, err := nomad.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+
 	// get the name of this application
 	appName, err := GetAppName(appPath)
 	if err != nil {
 		os.Exit(1)
 	}
+	// data files can refer to our services and the application we are starting
+	options, err := dataOptions(cmd, appPath, appName, hashLabel, consulService, vaultService, nomadService)
+	if err != nil {
+		os.Exit(1)
+	}
+	options.Prune = viper.GetBool("prune")
+	// vault refuses to prune secrets that aren't under a path of the app's own, so we check before changing anything
+	if options.Prune && options.SecretPath == "" {
+		log.Error().Msg("--prune needs the vault_path_template setting so that we only remove this application's secrets")
+		os.Exit(1)
+	}
+
+	// if we are only asked what would change we stop here
+	if viper.GetBool("dry-run") {
+		err = showDiff(appPath, options, consulService, vaultService)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// load up our config data
-	err = consulService.Load(filepath.Join(appPath, "infra/data.yml"), appName, hashLabel)
+	err = consulService.Load(filepath.Join(appPath, "infra/data.yml"), options)
 	if err != nil {
 		os.Exit(1)
 	}
 
 	// load up our vault instance
-	err = vaultService.Load(filepath.Join(appPath, "infra/secrets.yml"))
+	err = vaultService.Load(filepath.Join(appPath, "infra/secrets.yml"), options)
 	if err != nil {
 		os.Exit(1)
 	}
+	// along with the policies our jobs use to read it
+	_, err = vaultService.LoadPolicies(appName, filepath.Join(appPath, "infra/policies"), filepath.Join(appPath, "infra/secrets.yml"), options)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// when consul is running with ACLs our jobs get a token of their own
+	jobEnv := map[string]string{}
+	consulToken, err := consulService.AppToken(appName, options.KeyPrefix)
+	if err != nil {
+		os.Exit(1)
+	}
+	if consulToken != "" {
+		jobEnv[consulapi.HTTPTokenEnvName] = consulToken
+	}
+
+	// and finally run our application jobs
+	jobFiles, err := filepath.Glob(filepath.Join(appPath, "infra/*.nomad"))
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not search for job files in %s:", appPath)
+		os.Exit(1)
+	}
+	if len(jobFiles) == 0 {
+		log.Warn().Msg("No job files found. Skipping.")
+	}
+	for _, jobFile := range jobFiles {
+		err = nomadService.Run(jobFile, appName, hashLabel, jobEnv, time.Duration(viper.GetInt("jobTimeout"))*time.Second)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+}
+
+// dataOptions will return the options that data files are read with.  This includes the values that they can refer to
+// and where the data and secrets are loaded.
+func dataOptions(cmd *cobra.Command, appPath, appName, hashLabel string, consulService *consul.Service, vaultService *vault.Service, nomadService *nomad.Service) (utility.DataOptions, error) {
+	options := utility.DataOptions{
+		Environment: stringSetting(cmd, "env", "env"),
+		Encoding:    viper.GetString(projectKey("data_encoding")),
+		Vars: map[string]string{
+			"project":        viper.GetString("project"),
+			"app":            appName,
+			"hash_label":     hashLabel,
+			"environment":    stringSetting(cmd, "env", "env"),
+			"consul.address": consulService.Address,
+			"vault.address":  vaultService.Address,
+			"nomad.address":  nomadService.Address,
+		},
+	}
+	var err error
+	options.KeyPrefix, err = keyPrefix(appPath, options.Vars)
+	if err != nil {
+		return options, err
+	}
+	options.SecretPath, err = secretPath(options.Vars)
+	return options, err
 }
 
 // GetAppName will pull the name of the application from the appPath provided (it expects that appPath is a git repo)
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	terrarium "github.com/dansteen/terrarium/service"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ServiceStatus is the reported state of a single support service
+type ServiceStatus struct {
+	Name    string `json:"name"`
+	Pid     int    `json:"pid"`
+	Version string `json:"version"`
+	Address string `json:"address"`
+	Datadir string `json:"datadir"`
+	Logfile string `json:"logfile"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status will report on the health of each of the support services in this environment
+func Status(cmd *cobra.Command, args []string) {
+	// grab our workspace
+	workspace := viper.GetString("workspace")
+
+	// check to see if it exists
+	if _, err := os.Stat(workspace); err != nil {
+		log.Error().Err(err).Msgf("Could not find workspace %s: ", workspace)
+		os.Exit(1)
+	}
+
+	// let the user abort our health checks
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	statuses := []ServiceStatus{}
+
+	consulInstance, err := consul.GetService(workspace)
+	statuses = append(statuses, serviceStatus(ctx, consulInstance, err))
+
+	vaultInstance, err := vault.GetService(workspace)
+	statuses = append(statuses, serviceStatus(ctx, vaultInstance, err))
+
+	nomadInstance, err := nomad.GetService(workspace)
+	statuses = append(statuses, serviceStatus(ctx, nomadInstance, err))
+
+	// and report back in the requested format
+	if viper.GetBool("json") {
+		output, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			log.Error().Err(err).Msg("Could not generate status output")
+			os.Exit(1)
+		}
+		fmt.Println(string(output))
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(writer, "SERVICE\tPID\tVERSION\tADDRESS\tDATADIR\tLOGFILE\tHEALTH")
+		for _, status := range statuses {
+			health := "healthy"
+			if !status.Healthy {
+				health = fmt.Sprintf("unhealthy (%s)", status.Error)
+			}
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", status.Name, status.Pid, status.Version, status.Address, status.Datadir, status.Logfile, health)
+		}
+		writer.Flush()
+	}
+
+	// we exit non-zero if anything is unhealthy so that scripts can gate on it
+	for _, status := range statuses {
+		if !status.Healthy {
+			os.Exit(1)
+		}
+	}
+}
+
+// serviceStatus will check the health of a service and gather the values we report on
+func serviceStatus(ctx context.Context, instance terrarium.SupportService, loadErr error) ServiceStatus {
+	generic := instance.Base()
+	status := ServiceStatus{
+		Name:    strings.Title(instance.Name()),
+		Pid:     generic.Pid,
+		Version: generic.Version,
+		Address: generic.Address,
+		Datadir: generic.Datadir,
+		Logfile: generic.Logfile,
+	}
+	// if we could not load the service there is nothing to check
+	if loadErr != nil {
+		status.Error = loadErr.Error()
+		return status
+	}
+	if generic.Pid == 0 {
+		status.Error = "not initialized"
+		return status
+	}
+
+	// we only want to know how things are right now so we don't wait around as long as we do during init
+	instance.SetHealthyTimeout(viper.GetInt("timeout"))
+	healthy, err := instance.Healthy(ctx)
+	status.Healthy = healthy
+	if err != nil {
+		status.Error = err.Error()
+	} else if !healthy {
+		status.Error = "reported unhealthy"
+	}
+	return status
+}
@@ -0,0 +1,66 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dansteen/terrarium/consul"
+	"github.com/dansteen/terrarium/nomad"
+	"github.com/dansteen/terrarium/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// StopApp will stop an application in this environment and remove the data that was loaded for it
+func StopApp(cmd *cobra.Command, args []string) {
+	// grab our workspace
+	workspace := viper.GetString("workspace")
+	appPath := args[0]
+	hashLabel := viper.GetString("hashLabel")
+
+	// get our service information
+	consulService, err := consul.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+	vaultService, err := vault.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+	nomadService, err := nomad.GetService(workspace)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// get the name of this application
+	appName, err := GetAppName(appPath)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// work out where the application's data and secrets were loaded
+	options, err := dataOptions(cmd, appPath, appName, hashLabel, consulService, vaultService, nomadService)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// first stop the application itself
+	err = nomadService.StopApp(appName, viper.GetBool("purge"))
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// then clean up its data
+	err = consulService.Unload(options.KeyPrefix)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// secrets are only removed if asked since they are not namespaced by application
+	if viper.GetBool("secrets") {
+		err = vaultService.Unload(filepath.Join(appPath, "infra/secrets.yml"), options)
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+}
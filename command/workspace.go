@@ -0,0 +1,103 @@
+package command
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// ProjectMarker is the name of the file that marks the root of a project.  It holds project settings (e.g. the name of
+// the project) that are merged into the terrarium config.
+const ProjectMarker = ".terrarium.yml"
+
+// workspaceMarker is the name of the file that marks a directory as a terrarium workspace.  It holds the project name.
+const workspaceMarker = ".terrarium_workspace"
+
+// WorkspaceRoot will return the directory that project workspaces live in.  This is the workspace_root setting, or
+// $XDG_DATA_HOME/terrarium by default.
+func WorkspaceRoot() (string, error) {
+	if root := viper.GetString("workspace_root"); root != "" {
+		return homedir.Expand(root)
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "terrarium"), nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "terrarium"), nil
+}
+
+// FindProjectMarker will search dir and each of its parents for a project marker and return the path to the first one
+// it finds
+func FindProjectMarker(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		marker := filepath.Join(dir, ProjectMarker)
+		if info, err := os.Stat(marker); err == nil && !info.IsDir() {
+			return marker, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// markWorkspace will create the workspace marker in a workspace
+func markWorkspace(workspace string) error {
+	err := ioutil.WriteFile(filepath.Join(workspace, workspaceMarker), []byte(viper.GetString("project")+"\n"), 0644)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not mark %s as a terrarium workspace", workspace)
+	}
+	return err
+}
+
+// isWorkspace will return true if dir has been marked as a terrarium workspace
+func isWorkspace(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, workspaceMarker))
+	return err == nil && !info.IsDir()
+}
+
+// workspaceProject will return the name of the project a workspace belongs to
+func workspaceProject(workspace string) (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(workspace, workspaceMarker))
+	if err != nil {
+		return "", err
+	}
+	project := strings.TrimSpace(string(content))
+	if project == "" {
+		return "", errors.New("workspace marker does not name a project")
+	}
+	return project, nil
+}
+
+// knownWorkspaces will return the workspaces of all of the projects on this machine
+func knownWorkspaces() []string {
+	workspaces := []string{}
+	root, err := WorkspaceRoot()
+	if err != nil {
+		return workspaces
+	}
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		return workspaces
+	}
+	for _, dir := range dirs {
+		if dir.IsDir() && isWorkspace(filepath.Join(root, dir.Name())) {
+			workspaces = append(workspaces, filepath.Join(root, dir.Name()))
+		}
+	}
+	return workspaces
+}
@@ -0,0 +1,96 @@
+package consul
+
+import (
+	"fmt"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/rs/zerolog/log"
+	"github.com/satori/go.uuid"
+)
+
+// ACLsEnabled will return true if this instance runs with ACLs enabled
+func (service *Service) ACLsEnabled() bool {
+	return service.ACLToken != ""
+}
+
+// SetACLs will turn ACLs on or off for this instance.  With ACLs on consul denies anything that is not explicitly
+// allowed, just as it does in production, and we generate a management token for ourselves to use.
+func (service *Service) SetACLs(enabled bool) error {
+	if enabled == service.ACLsEnabled() {
+		return nil
+	}
+	if enabled {
+		token, err := uuid.NewV4()
+		if err != nil {
+			log.Error().Err(err).Msg("Could not generate a management token for consul:")
+			return err
+		}
+		service.ACLToken = token.String()
+	} else {
+		service.ACLToken = ""
+	}
+	service.setACLConfigVars()
+	return service.connect()
+}
+
+// setACLConfigVars will make our acl setup available to the config template
+func (service *Service) setACLConfigVars() {
+	service.SetConfigVar("acl_master_token", service.ACLToken)
+	// consul moved its acl settings into an acl stanza in 1.4
+	if service.VersionAtLeast("1.4.0") {
+		service.SetConfigVar("acl_stanza", "true")
+	} else {
+		service.SetConfigVar("acl_stanza", "")
+	}
+}
+
+// AppToken will create (or update) a token for an application that can only read the keys under its key prefix and
+// register the application's services.  If ACLs are not enabled it returns an empty token.
+func (service *Service) AppToken(appName, prefix string) (string, error) {
+	if !service.ACLsEnabled() {
+		return "", nil
+	}
+	name := fmt.Sprintf("terrarium-%s", appName)
+	rules := fmt.Sprintf(appRulesTemplate, prefix, appName)
+
+	// reuse the token if we have already made one for this app
+	entries, _, err := service.client.ACL().List(&consul.QueryOptions{})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not list consul tokens")
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Name != name {
+			continue
+		}
+		entry.Rules = rules
+		_, err = service.client.ACL().Update(entry, &consul.WriteOptions{})
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not update the consul token for %s", appName)
+			return "", err
+		}
+		return entry.ID, nil
+	}
+
+	id, _, err := service.client.ACL().Create(&consul.ACLEntry{
+		Name:  name,
+		Type:  consul.ACLClientType,
+		Rules: rules,
+	}, &consul.WriteOptions{})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not create a consul token for %s", appName)
+		return "", err
+	}
+	log.Info().Msgf("Created consul token for %s", appName)
+	return id, nil
+}
+
+// appRulesTemplate are the rules for an application token.  It is filled in with the key prefix and name of the app.
+const appRulesTemplate = `
+key "%s" {
+  policy = "read"
+}
+service "%s" {
+  policy = "write"
+}
+`
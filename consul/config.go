@@ -0,0 +1,43 @@
+package consul
+
+// defaultConfigTemplate is the built in template for the consul server config.  ACLs are only set up when they have been
+// enabled.  It can be overridden per project.
+const defaultConfigTemplate = `
+bootstrap_expect = 1
+bind_addr        = "127.0.0.1"
+advertise_addr   = "127.0.0.1"
+client_addr      = "127.0.0.1"
+enable_syslog    = true
+ui               = true
+datacenter       = "terrarium"
+server           = true
+
+ports {
+  http     = {{ .Ports.http }}
+  server   = {{ .Ports.rpc }}
+  serf_lan = {{ .Ports.serf_lan }}
+  serf_wan = {{ .Ports.serf_wan }}
+  dns      = {{ .Ports.dns }}
+}
+{{- if .Vars.acl_master_token }}
+{{- if .Vars.acl_stanza }}
+
+acl {
+  enabled        = true
+  default_policy = "deny"
+  down_policy    = "extend-cache"
+  tokens {
+    master = "{{ .Vars.acl_master_token }}"
+    agent  = "{{ .Vars.acl_master_token }}"
+  }
+}
+{{- else }}
+
+acl_datacenter     = "terrarium"
+acl_default_policy = "deny"
+acl_down_policy    = "extend-cache"
+acl_master_token   = "{{ .Vars.acl_master_token }}"
+acl_agent_token    = "{{ .Vars.acl_master_token }}"
+{{- end }}
+{{- end }}
+`
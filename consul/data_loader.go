@@ -1,19 +1,18 @@
 package consul
 
 import (
-	"io/ioutil"
+	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/dansteen/terrarium/utility"
 	consul "github.com/hashicorp/consul/api"
 	"github.com/rs/zerolog/log"
-	yaml "gopkg.in/yaml.v2"
 )
 
-// Load will accept a path to a yaml file and will load the content of that file into consul using the methodology described here:
-// https://github.com/traitify/ops_scripts/blob/master/CONSUL_ORGANIZATION.md#app
-func (service *Service) Load(dataFile, appName, hashLabel string) error {
+// Load will accept a path to a yaml file and will load the content of that file into consul under the key prefix in
+// options.  options also control which environment is loaded and the values that the file can reference.
+func (service *Service) Load(dataFile string, options utility.DataOptions) error {
 
 	// make sure the file exists
 	if _, err := os.Stat(dataFile); err != nil {
@@ -21,25 +20,129 @@ func (service *Service) Load(dataFile, appName, hashLabel string) error {
 		return nil
 	}
 
-	// read the file
-	content, err := ioutil.ReadFile(dataFile)
+	// work out what needs to change
+	changes, err := service.Diff(dataFile, options)
 	if err != nil {
-		log.Error().Err(err).Msgf("Error reading config file at %s.", dataFile)
 		return err
 	}
-	// create our data structure
-	data := utility.YamlData{DataType: "consul"}
-	yaml.Unmarshal(content, &data)
+	prefix := options.KeyPrefix
+	ops := consul.KVTxnOps{}
+	for _, change := range changes {
+		switch change.Action {
+		case utility.Added, utility.Changed:
+			ops = append(ops, &consul.KVTxnOp{Verb: consul.KVSet, Key: prefix + change.Key, Value: []byte(change.New)})
+		case utility.Removed:
+			if options.Prune {
+				ops = append(ops, &consul.KVTxnOp{Verb: consul.KVDelete, Key: prefix + change.Key})
+			}
+		}
+	}
+
+	// and make the changes
+	err = service.applyTxn(ops)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not load application data file %s to consul:", dataFile)
+		return err
+	}
 
-	// run through our records and create keys
-	for key, value := range data.Records {
-		_, err = service.client.KV().Put(&consul.KVPair{Key: filepath.Join("app", appName, hashLabel, key), Value: []byte(value)}, &consul.WriteOptions{})
+	log.Info().Msgf("Loaded data file %s into consul (%d keys changed)", dataFile, len(ops))
+	return nil
+}
+
+// the most operations that consul will accept in a single transaction
+const maxTxnOps = 64
+
+// applyTxn will apply ops to the consul kv store in transactions so that applications don't see a half written
+// configuration.  Consul limits the size of a transaction, so large changes are applied in chunks that are each atomic.
+func (service *Service) applyTxn(ops consul.KVTxnOps) error {
+	if len(ops) > maxTxnOps {
+		log.Warn().Msgf("%d changes is more than fit in a single consul transaction. Applying them in chunks.", len(ops))
+	}
+	for start := 0; start < len(ops); start += maxTxnOps {
+		end := start + maxTxnOps
+		if end > len(ops) {
+			end = len(ops)
+		}
+		ok, response, _, err := service.client.KV().Txn(ops[start:end], &consul.QueryOptions{})
 		if err != nil {
-			log.Error().Err(err).Msgf("Could not load application data file %s to consul:", dataFile)
 			return err
 		}
+		if !ok {
+			failures := []string{}
+			for _, txnErr := range response.Errors {
+				failures = append(failures, fmt.Sprintf("%s: %s", ops[start+txnErr.OpIndex].Key, txnErr.What))
+			}
+			return fmt.Errorf("transaction rolled back: %s", strings.Join(failures, ", "))
+		}
+	}
+	return nil
+}
+
+// Unload will remove the data loaded for an application by Load.  prefix is the key prefix it was loaded under.
+func (service *Service) Unload(prefix string) error {
+	if err := checkPrefix(prefix); err != nil {
+		return err
+	}
+	_, err := service.client.KV().DeleteTree(prefix, &consul.WriteOptions{})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not remove application data at %s from consul:", prefix)
+		return err
 	}
 
-	log.Info().Msgf("Loaded data file %s into consul", dataFile)
+	log.Info().Msgf("Removed data at %s from consul", prefix)
+	return nil
+}
+
+// Diff will compare the data in dataFile with the data currently loaded under the key prefix in options and return the
+// keys that differ
+func (service *Service) Diff(dataFile string, options utility.DataOptions) ([]utility.Change, error) {
+	wanted := map[string]string{}
+	if _, err := os.Stat(dataFile); err == nil {
+		data := utility.YamlData{DataType: "consul", DataOptions: options}
+		err = data.Read(dataFile)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error reading config file at %s.", dataFile)
+			return nil, err
+		}
+		wanted = data.Records
+	}
+
+	current, err := service.Export(options.KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return utility.Diff(current, wanted), nil
+}
+
+// Export will return the data that is currently loaded under the key prefix of an application, keyed the same way as the
+// data in a data file once it has been read
+func (service *Service) Export(prefix string) (map[string]string, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	pairs, _, err := service.client.KV().List(prefix, &consul.QueryOptions{})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not read application data at %s from consul:", prefix)
+		return nil, err
+	}
+	current := map[string]string{}
+	for _, pair := range pairs {
+		// folders don't hold any data
+		if strings.HasSuffix(pair.Key, "/") {
+			continue
+		}
+		current[strings.TrimPrefix(pair.Key, prefix)] = string(pair.Value)
+	}
+	return current, nil
+}
+
+// checkPrefix will make sure that a key prefix is limited to a single application so that we never touch the whole kv
+// store
+func checkPrefix(prefix string) error {
+	if strings.Trim(prefix, "/") == "" || !strings.HasSuffix(prefix, "/") {
+		err := fmt.Errorf("key prefix %q must be a directory below the root of consul", prefix)
+		log.Error().Err(err).Msg("Refusing to use consul key prefix")
+		return err
+	}
 	return nil
 }
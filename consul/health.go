@@ -0,0 +1,25 @@
+package consul
+
+import (
+	"context"
+
+	terrarium "github.com/dansteen/terrarium/service"
+	consul "github.com/hashicorp/consul/api"
+)
+
+// Healthy will check the health of the consul instance
+func (service *Service) Healthy(ctx context.Context) (bool, error) {
+	return service.WaitHealthy(ctx, service.probe)
+}
+
+// probe will ask consul if it thinks it is healthy
+func (service *Service) probe() error {
+	health, err := service.client.Operator().AutopilotServerHealth(&consul.QueryOptions{})
+	if err != nil {
+		return service.Unhealthy(terrarium.ReasonAPIUnreachable, err)
+	}
+	if !health.Healthy {
+		return service.Unhealthy(terrarium.ReasonUnhealthy, nil)
+	}
+	return nil
+}
@@ -1,63 +1,71 @@
 package consul
 
 import (
-	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
-	"runtime"
-	"strings"
-	"time"
+
+	yaml "gopkg.in/yaml.v2"
 
 	"github.com/dansteen/terrarium/service"
 	consul "github.com/hashicorp/consul/api"
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultVersion is the version of consul we run if one is not requested
+const DefaultVersion = "1.1.0"
+
 // Service is an instance of this service
 type Service struct {
-	service.Generic
-	client *consul.Client
+	// our state file has always been flat so we keep it that way
+	service.Generic `yaml:",inline"`
+	// the management token for consul.  This is only set when ACLs are enabled.
+	ACLToken string `yaml:"acl_token"`
+	client   *consul.Client
 }
 
-// NewService will create a initialize an instance of the service with default values
-func NewService(workspace string) (*Service, error) {
+// NewService will create a initialize an instance of the service with default values.  If version is empty we keep the
+// version of an existing instance, or use DefaultVersion for a new one.
+func NewService(workspace, version string) (*Service, error) {
 	// first initialize the generic stuff
 	newService := Service{}
 	newService.SetName("consul")
-	// our config for the application. this is much easier than trying to work with hcl in a write context
-	newService.SetServiceConfig(`
-bootstrap_expect: 1
-advertise_addr: "127.0.0.1"
-client_addr: "127.0.0.1"
-enable_syslog: true
-ui: true
-datacenter: "terrarium"
-server: true
-`)
 	newService.SetWorkspace(workspace)
+	// pick up any values from an existing instance in this workspace so that we keep using the same ports
+	_, err := newService.Read()
+	if err != nil {
+		return &newService, err
+	}
+	// each workspace gets its own set of ports so that multiple environments can run side by side
+	err = newService.AllocatePorts("http", "rpc", "serf_lan", "serf_wan", "dns")
+	if err != nil {
+		return &newService, err
+	}
+	newService.SetServiceConfig(defaultConfigTemplate)
 	newService.SetHealthyTimeout(30)
-	newService.Version = "1.1.0"
+	newService.SetStopTimeout(10)
+	// if no version is requested we keep running whatever the existing instance runs so that we never downgrade it
+	if version == "" {
+		version = newService.Version
+	}
+	if version == "" {
+		version = DefaultVersion
+	}
+	newService.SetVersion(version)
 	newService.ServiceConfigName = "consul_server.hcl"
-	// hardcoded for now.  We need to change this to support multiple simultanious environments
-	newService.Address = "127.0.0.1:8500"
+	newService.Address = fmt.Sprintf("127.0.0.1:%d", newService.Port("http"))
 	newService.Datadir = filepath.Join(workspace, newService.Name()+".d")
 	newService.Logfile = filepath.Join(workspace, newService.Name()+".log")
 
-	newService.Cmdline = fmt.Sprintf("%s agent -data-dir \"%s\" -config-file %s &> \"%s\"", filepath.Join(workspace, newService.Name()), newService.Datadir, newService.ServiceConfigName, newService.Logfile)
-	newService.DownloadURL = fmt.Sprintf("https://releases.hashicorp.com/%s/%s/%s_%s_%s_%s.zip", newService.Name(), newService.Version, newService.Name(), newService.Version, runtime.GOOS, runtime.GOARCH)
+	newService.Cmdline = fmt.Sprintf("%s agent -data-dir \"%s\" -config-file \"%s\" &> \"%s\"", filepath.Join(workspace, newService.Name()), newService.Datadir, filepath.Join(newService.Datadir, newService.ServiceConfigName), newService.Logfile)
 
-	// create a consul connection
-	client, err := consul.NewClient(&consul.Config{
-		Address: newService.Address,
-		Scheme:  "http",
-	})
-	if err != nil {
-		log.Error().Err(err)
-		return &newService, err
-	}
-	newService.client = client
+	// keep the acl setup of any existing instance
+	newService.setACLConfigVars()
 
-	return &newService, nil
+	// create a consul connection
+	err = newService.connect()
+	return &newService, err
 }
 
 // GetService will get the existing service in a workspace (if it exists)
@@ -65,6 +73,8 @@ func GetService(workspace string) (*Service, error) {
 	service := Service{}
 	service.SetName("consul")
 	service.SetWorkspace(workspace)
+	service.SetHealthyTimeout(30)
+	service.SetStopTimeout(10)
 	found, err := service.Read()
 	if err != nil || !found {
 		log.Error().Err(err).Msgf("Could not get existing %s service in workspace %s", service.Name(), service.Workspace())
@@ -72,61 +82,66 @@ func GetService(workspace string) (*Service, error) {
 	}
 
 	// create a consul connection
+	err = service.connect()
+	return &service, err
+}
+
+// connect will set up our client connection to consul
+func (service *Service) connect() error {
 	client, err := consul.NewClient(&consul.Config{
 		Address: service.Address,
 		Scheme:  "http",
+		Token:   service.ACLToken,
 	})
 	if err != nil {
 		log.Error().Err(err)
-		return &service, err
+		return err
 	}
 	service.client = client
-
-	return &service, nil
+	return nil
 }
 
-// Healthy will check the health of the consul instance
-func (service *Service) Healthy() (bool, error) {
-	// first run our generic check
-	healthy, err := service.Generic.Healthy()
-	if err != nil {
-		return healthy, err
-	}
+// Read will read an existing instance.  We need to overide the generic reader here to ensure that we get our extra stanzas
+func (service *Service) Read() (bool, error) {
+	// the location of the config file
+	configPath := filepath.Join(service.Workspace(), service.Name()+".yml")
 
-	// then check to see if it thinks its healthy
-	client, err := consul.NewClient(&consul.Config{
-		Address: service.Address,
-		Scheme:  "http",
-	})
-	if err != nil {
-		log.Error().Err(err)
-		return false, err
-	}
+	// if there is existing instance data
+	if _, err := os.Stat(configPath); err == nil {
+		// if there is read it in (these files are short so we can read the whole thing)
+		content, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error reading config file at %s.", configPath)
+			return false, err
+		}
 
-	// set up our timer
-	timeout := make(chan bool, 1)
-	go func() {
-		time.Sleep(30 * time.Second)
-		timeout <- false
-	}()
-
-	// keep this up until things return or we timeout
-	for true {
-		select {
-		// we give things 30 seconds to come up
-		case <-timeout:
-			err = fmt.Errorf("Timeout exceeded while starting %s", strings.Title(service.Name()))
-			log.Error().Err(err)
+		err = yaml.Unmarshal(content, service)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error processing config file content: %s.", configPath)
 			return false, err
-		default:
-			// grab our health and return if its good
-			health, err := client.Operator().AutopilotServerHealth(&consul.QueryOptions{})
-			if err == nil {
-				return health.Healthy, nil
-			}
-			// pause for a second to let things run
-			time.Sleep(1 * time.Second)
 		}
+		return true, nil
+	}
+	// we return false if there is no config file to read
+	return false, nil
+}
+
+// Write will write instance data to a file in workspace named <app>.yml.   We need to overide the generic reader here to ensure that we get our extra stanzas
+func (service *Service) Write() error {
+	// the location of the config file
+	configPath := filepath.Join(service.Workspace(), service.Name()+".yml")
+
+	// marshall our data
+	data, err := yaml.Marshal(service)
+	if err != nil {
+		log.Error().Err(err).Msg("Error processing config data for writing")
+		return err
+	}
+	// and write it out.  This holds our management token so only we can read it.
+	err = ioutil.WriteFile(configPath, data, 0600)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error writing config data to %s", configPath)
+		return err
 	}
-	return false, errors.New("We shouldn't be here")
+	return nil
 }
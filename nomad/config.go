@@ -0,0 +1,34 @@
+package nomad
+
+// defaultConfigTemplate is the built in template for the nomad agent config.  It can be overridden per project.
+const defaultConfigTemplate = `
+datacenter = "terrarium"
+bind_addr  = "127.0.0.1"
+
+ports {
+  http = {{ .Ports.http }}
+  rpc  = {{ .Ports.rpc }}
+  serf = {{ .Ports.serf }}
+}
+
+server {
+  enabled          = true
+  bootstrap_expect = 1
+  raft_protocol    = 3
+}
+client {
+  enabled = true
+}
+consul {
+  server_auto_join = true
+  address          = "{{ .Vars.consul_address }}"
+  token            = "{{ .Vars.consul_token }}"
+}
+vault {
+  enabled               = true
+  token                 = "{{ .Vars.vault_token }}"
+  address               = "{{ .Vars.vault_address }}"
+  create_from_role      = "nomad-cluster"
+  allow_unauthenticated = true
+}
+`
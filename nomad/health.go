@@ -0,0 +1,42 @@
+package nomad
+
+import (
+	"context"
+	"errors"
+
+	terrarium "github.com/dansteen/terrarium/service"
+	nomad "github.com/hashicorp/nomad/api"
+)
+
+// Healthy will check the health of the nomad instance
+func (service *Service) Healthy(ctx context.Context) (bool, error) {
+	return service.WaitHealthy(ctx, service.probe)
+}
+
+// the first version of nomad that serves the autopilot health endpoint
+const autopilotHealthVersion = "0.9.0"
+
+// probe will ask nomad if it thinks it is healthy
+func (service *Service) probe() error {
+	// newer versions of nomad can tell us about the health of the servers directly
+	if service.VersionAtLeast(autopilotHealthVersion) {
+		health, _, err := service.client.Operator().AutopilotServerHealth(&nomad.QueryOptions{})
+		if err != nil {
+			return service.Unhealthy(terrarium.ReasonAPIUnreachable, err)
+		}
+		if !health.Healthy {
+			return service.Unhealthy(terrarium.ReasonUnhealthy, nil)
+		}
+		return nil
+	}
+
+	// older versions don't have the autopilot health endpoint so we just see if we can get a leader out of nomad
+	leader, err := service.client.Status().Leader()
+	if err != nil {
+		return service.Unhealthy(terrarium.ReasonAPIUnreachable, err)
+	}
+	if leader == "" {
+		return service.Unhealthy(terrarium.ReasonUnhealthy, errors.New("no leader elected"))
+	}
+	return nil
+}
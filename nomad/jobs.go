@@ -0,0 +1,175 @@
+package nomad
+
+import (
+	"fmt"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/jobspec"
+	"github.com/rs/zerolog/log"
+)
+
+// meta keys that we add to every job we run so that we can find them again later
+const (
+	AppMetaKey       = "terrarium_app"
+	HashLabelMetaKey = "hash_label"
+)
+
+// Run will parse the provided job file, tag it with our application information and register it with nomad.  Each task
+// in the job gets the variables in env added to its environment.  It will then wait up to timeout for the allocations of
+// the job to become healthy.
+func (service *Service) Run(jobFile, appName, hashLabel string, env map[string]string, timeout time.Duration) error {
+	// we parse the job with nomad's own jobspec parser so we get the same behavior as the nomad cli
+	job, err := jobspec.ParseFile(jobFile)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not parse job file %s:", jobFile)
+		return err
+	}
+	job.Canonicalize()
+
+	// tag the job with our application information
+	if job.Meta == nil {
+		job.Meta = make(map[string]string)
+	}
+	job.Meta[AppMetaKey] = appName
+	if hashLabel != "" {
+		job.Meta[HashLabelMetaKey] = hashLabel
+	}
+
+	// and give its tasks anything else they need to run here
+	for _, group := range job.TaskGroups {
+		for _, task := range group.Tasks {
+			if task.Env == nil {
+				task.Env = make(map[string]string)
+			}
+			for key, value := range env {
+				task.Env[key] = value
+			}
+		}
+	}
+
+	// and send it off to nomad
+	log.Info().Msgf("Registering job %s from %s", *job.ID, jobFile)
+	_, _, err = service.client.Jobs().Register(job, &nomad.WriteOptions{})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not register job %s:", *job.ID)
+		return err
+	}
+
+	return service.waitForJob(*job.ID, timeout)
+}
+
+// waitForJob will wait for all of the allocations of the current version of a job to become healthy
+func (service *Service) waitForJob(jobID string, timeout time.Duration) error {
+	log.Info().Msgf("Waiting %s for job %s to become healthy", timeout, jobID)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		healthy, err := service.jobHealthy(jobID)
+		if err != nil {
+			log.Error().Err(err).Msgf("Job %s failed:", jobID)
+			return err
+		}
+		if healthy {
+			log.Info().Msgf("Job %s is healthy", jobID)
+			return nil
+		}
+		// pause for a second to let things run
+		time.Sleep(1 * time.Second)
+	}
+	err := fmt.Errorf("Timeout exceeded while waiting for job %s to become healthy", jobID)
+	log.Error().Err(err)
+	return err
+}
+
+// jobHealthy will check to see if all of the allocations for the current version of a job are healthy.  It will return an
+// error if any of them have failed.
+func (service *Service) jobHealthy(jobID string) (bool, error) {
+	job, _, err := service.client.Jobs().Info(jobID, &nomad.QueryOptions{})
+	if err != nil {
+		return false, err
+	}
+	allocations, _, err := service.client.Jobs().Allocations(jobID, false, &nomad.QueryOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	// figure out how many allocations we expect and which groups nomad will be tracking deployment health for
+	expected := 0
+	deployed := make(map[string]bool)
+	for _, group := range job.TaskGroups {
+		if group.Count != nil {
+			expected += *group.Count
+		}
+		if group.Name != nil && group.Update != nil && group.Update.MaxParallel != nil && *group.Update.MaxParallel > 0 {
+			deployed[*group.Name] = true
+		}
+	}
+
+	// then run through and see how many of them are good
+	healthy := 0
+	for _, allocation := range allocations {
+		// we only care about the allocations for the version we just registered
+		if job.Version == nil || allocation.JobVersion != *job.Version || allocation.DesiredStatus != "run" {
+			continue
+		}
+		switch allocation.ClientStatus {
+		case "failed", "lost":
+			return false, fmt.Errorf("allocation %s is %s: %s", allocation.ID, allocation.ClientStatus, allocation.ClientDescription)
+		case "complete":
+			// batch jobs are done when they complete
+			if job.Type != nil && *job.Type == "batch" {
+				healthy++
+			}
+		case "running":
+			// if the job is part of a deployment we wait for nomad to mark it healthy
+			if !deployed[allocation.TaskGroup] {
+				healthy++
+				continue
+			}
+			if allocation.DeploymentStatus == nil || allocation.DeploymentStatus.Healthy == nil {
+				continue
+			}
+			if !*allocation.DeploymentStatus.Healthy {
+				return false, fmt.Errorf("allocation %s is unhealthy", allocation.ID)
+			}
+			healthy++
+		}
+	}
+	return healthy >= expected, nil
+}
+
+// StopApp will deregister all of the jobs that were registered for an application.  If purge is true the jobs are removed
+// from nomad entirely rather than just being stopped.
+func (service *Service) StopApp(appName string, purge bool) error {
+	jobs, _, err := service.client.Jobs().List(&nomad.QueryOptions{})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not get the list of jobs from nomad:")
+		return err
+	}
+
+	stopped := 0
+	for _, stub := range jobs {
+		// the job list does not include meta information so we need to grab the whole job
+		job, _, err := service.client.Jobs().Info(stub.ID, &nomad.QueryOptions{})
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not get information for job %s:", stub.ID)
+			return err
+		}
+		if job.Meta[AppMetaKey] != appName {
+			continue
+		}
+
+		log.Info().Msgf("Deregistering job %s", stub.ID)
+		_, _, err = service.client.Jobs().Deregister(stub.ID, purge, &nomad.WriteOptions{})
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not deregister job %s:", stub.ID)
+			return err
+		}
+		stopped++
+	}
+
+	if stopped == 0 {
+		log.Warn().Msgf("No jobs found for %s.", appName)
+	}
+	return nil
+}
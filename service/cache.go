@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/rs/zerolog/log"
+)
+
+// Cache is a store of downloaded binaries that is shared by all projects.  Binaries are stored under
+// <dir>/<name>/<version>/<os>_<arch>/<name>.
+type Cache struct {
+	Dir string
+	// when offline we never download anything and fail if a binary is not in the cache
+	Offline bool
+}
+
+// CacheEntry is a single binary in the cache
+type CacheEntry struct {
+	Name     string
+	Version  string
+	Platform string
+	Path     string
+	Size     int64
+}
+
+// DefaultCacheDir will return the default location of the binary cache ($XDG_CACHE_HOME/terrarium/bin)
+func DefaultCacheDir() (string, error) {
+	if cacheHome := os.Getenv("XDG_CACHE_HOME"); cacheHome != "" {
+		return filepath.Join(cacheHome, "terrarium", "bin"), nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", "terrarium", "bin"), nil
+}
+
+// Path will return the location of a binary in the cache for this platform
+func (cache *Cache) Path(name, version string) string {
+	return filepath.Join(cache.Dir, name, version, runtime.GOOS+"_"+runtime.GOARCH, name)
+}
+
+// Entries will return all of the binaries in the cache
+func (cache *Cache) Entries() ([]CacheEntry, error) {
+	entries := []CacheEntry{}
+	paths, err := filepath.Glob(filepath.Join(cache.Dir, "*", "*", "*", "*"))
+	if err != nil {
+		return entries, err
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		platformDir := filepath.Dir(path)
+		versionDir := filepath.Dir(platformDir)
+		entries = append(entries, CacheEntry{
+			Name:     filepath.Base(filepath.Dir(versionDir)),
+			Version:  filepath.Base(versionDir),
+			Platform: filepath.Base(platformDir),
+			Path:     path,
+			Size:     info.Size(),
+		})
+	}
+	return entries, nil
+}
+
+// Remove will remove an entry from the cache along with any directories that are left empty
+func (cache *Cache) Remove(entry CacheEntry) error {
+	err := os.Remove(entry.Path)
+	if err != nil {
+		return err
+	}
+	// clean up the platform, version and name directories if there is nothing left in them
+	dir := filepath.Dir(entry.Path)
+	for i := 0; i < 3; i++ {
+		if os.Remove(dir) != nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return nil
+}
+
+// SetCache will set the shared binary cache that this service gets its binary from
+func (service *Generic) SetCache(cache *Cache) {
+	service.cache = cache
+}
+
+// BinaryPath will return the path of an already installed binary that we use instead of downloading one
+func (service *Generic) BinaryPath() string {
+	return service.binaryPath
+}
+
+// SetBinaryPath will set the path of an already installed binary that we use instead of downloading one
+func (service *Generic) SetBinaryPath(path string) {
+	service.binaryPath = path
+}
+
+// binarySource will return the location of the binary that the workspace binary should be taken from, downloading it
+// into the cache if needed.  An empty source means the binary lives directly in the workspace.
+func (service *Generic) binarySource() (string, error) {
+	// an already installed binary always wins
+	if service.BinaryPath() != "" {
+		if _, err := os.Stat(service.BinaryPath()); err != nil {
+			log.Error().Err(err).Msgf("Could not find %s binary at %s:", strings.Title(service.Name()), service.BinaryPath())
+			return "", err
+		}
+		return service.BinaryPath(), nil
+	}
+	if service.cache == nil {
+		return "", nil
+	}
+
+	// otherwise we use the cache
+	cached := service.cache.Path(service.Name(), service.Version)
+	if _, err := os.Stat(cached); err == nil {
+		log.Info().Msgf("Using cached %s %s", strings.Title(service.Name()), service.Version)
+		return cached, nil
+	}
+	log.Info().Msgf("%s %s not found in cache", strings.Title(service.Name()), service.Version)
+	err := os.MkdirAll(filepath.Dir(cached), 0755)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not create cache directory for %s:", strings.Title(service.Name()))
+		return "", err
+	}
+	err = service.download(cached)
+	if err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// linkBinary will point the workspace binary at source.  We use a symlink where we can and fall back to a copy.
+func (service *Generic) linkBinary(source string) error {
+	destination := filepath.Join(service.Workspace(), service.Name())
+	// if we are already pointing at the right place there is nothing to do
+	if target, err := os.Readlink(destination); err == nil && target == source {
+		return nil
+	}
+
+	// clear out whatever is there now
+	if _, err := os.Lstat(destination); err == nil {
+		err = os.Remove(destination)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not remove old %s binary:", strings.Title(service.Name()))
+			return err
+		}
+	}
+
+	err := os.Symlink(source, destination)
+	if err == nil {
+		return nil
+	}
+	log.Warn().Err(err).Msgf("Could not link %s binary. Copying instead.", strings.Title(service.Name()))
+	err = copyFile(source, destination)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not copy %s binary from %s:", strings.Title(service.Name()), source)
+	}
+	return err
+}
+
+// copyFile will copy an executable from source to destination
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(destination), filepath.Base(destination))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), 0755)
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), destination)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return fmt.Errorf("could not copy %s to %s: %s", source, destination, err)
+	}
+	return nil
+}
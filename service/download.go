@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/openpgp"
+)
+
+// DefaultReleasesURL is where we download hashicorp binaries from unless told otherwise
+const DefaultReleasesURL = "https://releases.hashicorp.com"
+
+// Download will download the app to our workspace.  The download is verified against the SHA256SUMS file for the
+// release, and if a signing key has been configured the SHA256SUMS file is verified against its signature.
+func (service *Generic) Download() error {
+	return service.download(filepath.Join(service.Workspace(), service.Name()))
+}
+
+// download will download the binary for the version of the service we want to destination
+func (service *Generic) download(destination string) error {
+	if service.cache != nil && service.cache.Offline {
+		err := fmt.Errorf("%s %s is not in the binary cache at %s and we are running offline", strings.Title(service.Name()), service.Version, service.cache.Dir)
+		log.Error().Err(err).Msgf("Could not get %s:", strings.Title(service.Name()))
+		return err
+	}
+
+	archive := fmt.Sprintf("%s_%s_%s_%s.zip", service.Name(), service.Version, runtime.GOOS, runtime.GOARCH)
+	service.DownloadURL = service.releaseURL(archive)
+
+	// find the checksum we expect the archive to have
+	sum, err := service.releaseChecksum(archive)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not verify %s download:", strings.Title(service.Name()))
+		return err
+	}
+
+	log.Info().Msgf("Downloading from %s...", service.DownloadURL)
+	err = getter.GetFile(destination, fmt.Sprintf("%s?checksum=sha256:%s", service.DownloadURL, sum))
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not download %s from %s:", strings.Title(service.Name()), service.DownloadURL)
+		// make sure we don't leave a partial or unverified binary around
+		os.Remove(destination)
+	}
+	return err
+}
+
+// ReleasesURL will return the base url we download releases from
+func (service *Generic) ReleasesURL() string {
+	if service.releasesURL == "" {
+		return DefaultReleasesURL
+	}
+	return service.releasesURL
+}
+
+// SetReleasesURL will set the base url we download releases from (e.g. a local mirror of releases.hashicorp.com)
+func (service *Generic) SetReleasesURL(url string) {
+	service.releasesURL = strings.TrimRight(url, "/")
+}
+
+// SigningKey will return the path to the armored public key that releases are signed with
+func (service *Generic) SigningKey() string {
+	return service.signingKey
+}
+
+// SetSigningKey will set the path to the armored public key that releases are signed with.  If this is set the
+// SHA256SUMS file of a release must have a valid signature from this key.
+func (service *Generic) SetSigningKey(path string) {
+	service.signingKey = path
+}
+
+// releaseURL will return the url of a file in the release of the version of the service we want
+func (service *Generic) releaseURL(file string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", service.ReleasesURL(), service.Name(), service.Version, file)
+}
+
+// releaseChecksum will return the sha256 checksum of a file in the release from the release's SHA256SUMS file
+func (service *Generic) releaseChecksum(file string) (string, error) {
+	sumsFile := fmt.Sprintf("%s_%s_SHA256SUMS", service.Name(), service.Version)
+	sums, err := fetch(service.releaseURL(sumsFile))
+	if err != nil {
+		return "", err
+	}
+
+	// make sure the sums are the ones that were published
+	if service.SigningKey() != "" {
+		signature, err := fetch(service.releaseURL(sumsFile + ".sig"))
+		if err != nil {
+			return "", err
+		}
+		err = verifySignature(service.SigningKey(), sums, signature)
+		if err != nil {
+			return "", fmt.Errorf("signature verification of %s failed: %s", sumsFile, err)
+		}
+	}
+
+	// each line of the file is "<sum>  <file>"
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == file {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s found in %s", file, sumsFile)
+}
+
+// verifySignature will check that signature is a valid detached signature of content by the key in keyPath
+func verifySignature(keyPath string, content, signature []byte) error {
+	key, err := os.Open(keyPath)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(key)
+	if err != nil {
+		return err
+	}
+	_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(content), bytes.NewReader(signature))
+	return err
+}
+
+// fetch will return the content at url
+func fetch(url string) ([]byte, error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch %s: %s", url, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
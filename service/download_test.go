@@ -0,0 +1,175 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// releaseServer is a stand in for releases.hashicorp.com that serves a single release of a service
+type releaseServer struct {
+	*httptest.Server
+	files map[string][]byte
+}
+
+// newReleaseServer will start a release server for version of name that holds a zip of binary.  If sum is empty the
+// real checksum of the zip is published.
+func newReleaseServer(t *testing.T, name, version string, binary []byte, sum string) *releaseServer {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, err := writer.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(binary)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum == "" {
+		sum = fmt.Sprintf("%x", sha256.Sum256(archive.Bytes()))
+	}
+	archiveName := fmt.Sprintf("%s_%s_%s_%s.zip", name, version, runtime.GOOS, runtime.GOARCH)
+	prefix := fmt.Sprintf("/%s/%s/", name, version)
+	server := &releaseServer{files: map[string][]byte{
+		prefix + archiveName: archive.Bytes(),
+		prefix + fmt.Sprintf("%s_%s_SHA256SUMS", name, version): []byte(fmt.Sprintf("%s  %s\n", sum, archiveName)),
+	}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := server.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	return server
+}
+
+// sign will publish a signature of the SHA256SUMS file of the release and return the path to the armored public key
+// that it was signed with
+func (server *releaseServer) sign(t *testing.T, dir, name, version string) string {
+	entity, err := openpgp.NewEntity("terrarium", "test", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sumsPath := fmt.Sprintf("/%s/%s/%s_%s_SHA256SUMS", name, version, name, version)
+	var signature bytes.Buffer
+	err = openpgp.DetachSign(&signature, entity, bytes.NewReader(server.files[sumsPath]), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.files[sumsPath+".sig"] = signature.Bytes()
+
+	var key bytes.Buffer
+	writer, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(writer); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	keyPath := filepath.Join(dir, "key.asc")
+	if err := ioutil.WriteFile(keyPath, key.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return keyPath
+}
+
+// testService will return a service in workspace that downloads from server
+func testService(server *releaseServer, workspace, name, version string) *Generic {
+	service := &Generic{}
+	service.SetName(name)
+	service.SetWorkspace(workspace)
+	service.Version = version
+	service.SetReleasesURL(server.URL)
+	return service
+}
+
+func TestDownloadVerifiesChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terrarium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newReleaseServer(t, "consul", "1.1.0", []byte("consul binary"), "")
+	defer server.Close()
+	destination := filepath.Join(dir, "consul")
+
+	err = testService(server, dir, "consul", "1.1.0").Download()
+	if err != nil {
+		t.Fatalf("download with a good checksum failed: %s", err)
+	}
+	content, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "consul binary" {
+		t.Errorf("downloaded binary is %q, not %q", content, "consul binary")
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terrarium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newReleaseServer(t, "consul", "1.1.0", []byte("consul binary"), fmt.Sprintf("%x", sha256.Sum256([]byte("something else"))))
+	defer server.Close()
+	destination := filepath.Join(dir, "consul")
+
+	err = testService(server, dir, "consul", "1.1.0").Download()
+	if err == nil {
+		t.Fatal("download with a bad checksum succeeded")
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("download with a bad checksum left a file at %s", destination)
+	}
+}
+
+func TestDownloadVerifiesSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terrarium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newReleaseServer(t, "vault", "0.10.1", []byte("vault binary"), "")
+	defer server.Close()
+	keyPath := server.sign(t, dir, "vault", "0.10.1")
+	destination := filepath.Join(dir, "vault")
+
+	service := testService(server, dir, "vault", "0.10.1")
+	service.SetSigningKey(keyPath)
+	err = service.Download()
+	if err != nil {
+		t.Fatalf("download with a good signature failed: %s", err)
+	}
+
+	// sums that don't match their signature are rejected
+	os.Remove(destination)
+	sumsPath := "/vault/0.10.1/vault_0.10.1_SHA256SUMS"
+	server.files[sumsPath] = append(server.files[sumsPath], []byte("0000  extra.zip\n")...)
+	err = service.Download()
+	if err == nil {
+		t.Fatal("download with a bad signature succeeded")
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("download with a bad signature left a file at %s", destination)
+	}
+}
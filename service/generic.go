@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,39 +9,70 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
-	getter "github.com/hashicorp/go-getter"
 	"github.com/rs/zerolog/log"
 	yaml "gopkg.in/yaml.v2"
 )
 
 // Generic is a generic service intended to be overridden
 type Generic struct {
-	name              string `yaml:"name"`
-	Cmdline           string `yaml:"cmdline"`
-	Address           string `yaml:"address"`
-	Pid               int    `yaml:"pid"`
-	Version           string `yaml:"version"`
-	Datadir           string `yaml:"datadir"`
-	Logfile           string `yaml:"logfile"`
-	DownloadURL       string `yaml:"download_url"`
-	ServiceConfigName string `yaml:"service_config_name"`
-	healthyTimeout    int    `yaml:"healthy_timeout"`
+	name              string         `yaml:"name"`
+	Cmdline           string         `yaml:"cmdline"`
+	Address           string         `yaml:"address"`
+	Ports             map[string]int `yaml:"ports"`
+	Pid               int            `yaml:"pid"`
+	Version           string         `yaml:"version"`
+	Datadir           string         `yaml:"datadir"`
+	Logfile           string         `yaml:"logfile"`
+	DownloadURL       string         `yaml:"download_url"`
+	ServiceConfigName string         `yaml:"service_config_name"`
+	healthyTimeout    int            `yaml:"healthy_timeout"`
+	stopTimeout       int
 	serviceConfig     string
+	configVars        map[string]string
+	previousVersion   string
+	restartReason     string
+	releasesURL       string
+	signingKey        string
+	cache             *Cache
+	binaryPath        string
 	workspace         string
+	// closed when a process we started exits
+	exited chan struct{}
 }
 
 // Init will generate a new service for this workspace unless one already exists
 // and will return true if it generates one and false if it exists
 func (service *Generic) Init() error {
 
-	// Make sure we have the binary we need
-	if _, err := os.Stat(filepath.Join(service.Workspace(), service.Name())); err != nil {
+	// Make sure we have the binary we need.  If it comes from the shared cache or an installed binary we link to it
+	source, err := service.binarySource()
+	if err != nil {
+		return err
+	}
+	if source != "" {
+		err = service.linkBinary(source)
+		if err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(filepath.Join(service.Workspace(), service.Name())); err != nil {
 		log.Info().Msgf("Existing %s binary not found", strings.Title(service.Name()))
 		err := service.Download()
 		if err != nil {
 			return err
 		}
+	} else if service.VersionChanged() {
+		log.Info().Msgf("Existing %s binary is version %s", strings.Title(service.Name()), service.PreviousVersion())
+		err := os.Remove(filepath.Join(service.Workspace(), service.Name()))
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not remove old %s binary:", strings.Title(service.Name()))
+			return err
+		}
+		err = service.Download()
+		if err != nil {
+			return err
+		}
 	}
 
 	// create our datadir if it does not exist
@@ -56,26 +88,19 @@ func (service *Generic) Init() error {
 
 }
 
-// Download will download the app to our environment
-func (service *Generic) Download() error {
-	destination := filepath.Join(service.Workspace(), service.Name())
-	log.Info().Msgf("Downloading from %s...", service.DownloadURL)
-	err := getter.GetFile(destination, service.DownloadURL)
-	if err != nil {
-		log.Error().Err(err).Msgf("Could not download %s from %s:", strings.Title(service.Name()), service.DownloadURL)
-	}
-	return err
-}
-
 // WriteServiceConfig will create the config for the application we are starting
 func (service *Generic) WriteServiceConfig() error {
 	// the location of the config file
 	configPath := filepath.Join(service.Datadir, service.ServiceConfigName)
-	// in the future we will need to instantiate this as a template, but for now this is fine
-	data := []byte(service.serviceConfig)
+	// our service config is a template so render it with the values for this instance
+	data, err := service.RenderServiceConfig()
+	if err != nil {
+		log.Error().Err(err).Msgf("Error rendering %s config template", service.Name())
+		return err
+	}
 
 	// and write it out
-	err := ioutil.WriteFile(configPath, data, 0644)
+	err = ioutil.WriteFile(configPath, data, 0644)
 	if err != nil {
 		log.Error().Err(err).Msgf("Error writing %s config data to %s", service.Name(), configPath)
 		return err
@@ -129,15 +154,14 @@ func (service *Generic) Write() error {
 }
 
 // Healthy will check the health of the service.  This will only check if the process exists. More advanced healthchecks
-// must be implemented by each SupportService.
-func (service *Generic) Healthy() (bool, error) {
-	// the first check is to see if the process is running
-	process, _ := os.FindProcess(service.Pid)
-	err := process.Signal(os.Signal(syscall.Signal(0x0)))
-	if err != nil {
-		return false, err
-	}
-	return true, nil
+// must be implemented by each SupportService by passing a Probe to WaitHealthy.
+func (service *Generic) Healthy(ctx context.Context) (bool, error) {
+	return service.WaitHealthy(ctx, nil)
+}
+
+// Base will return the generic part of the service so that its common values can be reached through a SupportService
+func (service *Generic) Base() *Generic {
+	return service
 }
 
 // Workspace will return the workspace that this service runs in
@@ -160,12 +184,12 @@ func (service *Generic) SetName(name string) {
 	service.name = name
 }
 
-// ServiceConfig will return the service config for this service
+// ServiceConfig will return the service config template for this service
 func (service *Generic) ServiceConfig() string {
 	return service.serviceConfig
 }
 
-// SetServiceConfig will return the workspace that this service runs in
+// SetServiceConfig will set the template used to generate the service config for this service
 func (service *Generic) SetServiceConfig(serviceConfig string) {
 	service.serviceConfig = serviceConfig
 }
@@ -180,7 +204,19 @@ func (service *Generic) SetHealthyTimeout(timeout int) {
 	service.healthyTimeout = timeout
 }
 
-// Start will start consul for this environemnt
+// StopTimeout will return the number of seconds we give the service to exit at each stage of stopping it
+func (service *Generic) StopTimeout() int {
+	return service.stopTimeout
+}
+
+// SetStopTimeout will set the number of seconds we give the service to exit at each stage of stopping it
+func (service *Generic) SetStopTimeout(timeout int) {
+	service.stopTimeout = timeout
+}
+
+// Start will start consul for this environemnt.  It does not write the state file with the new pid, since only the
+// service that embeds us knows everything that is in its state file, so callers must Write the service once it has
+// started.
 func (service *Generic) Start() error {
 	log.Info().Msgf("Starting %s", service.Name())
 	// start up our command
@@ -192,31 +228,85 @@ func (service *Generic) Start() error {
 	}
 	// save off some values
 	service.Pid = cmd.Process.Pid
-	// once it comes up write our config
-	err = service.Write()
-	if err != nil {
-		// stop the service so we dont leave running processes around
-		service.Stop()
-		return err
-	}
+	// reap the process when it exits so that it doesn't hang around as a zombie and look like it's still running
+	service.exited = make(chan struct{})
+	go func(exited chan struct{}) {
+		cmd.Wait()
+		close(exited)
+	}(service.exited)
 
 	log.Info().Msgf("Started")
 	return nil
 }
 
-// Stop will stop consul for this environment
+// Running will return true if the process for this service is still running
+func (service *Generic) Running() bool {
+	// if we started the process ourselves we know exactly when it exits
+	if service.exited != nil {
+		select {
+		case <-service.exited:
+			return false
+		default:
+			return true
+		}
+	}
+	// otherwise we see if we can signal it
+	if service.Pid == 0 {
+		return false
+	}
+	process, err := os.FindProcess(service.Pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// Stop will stop the service for this environment.  We first ask nicely with an interrupt, and if the service has not
+// exited within the stop timeout we escalate to a SIGTERM and finally a SIGKILL.
 func (service *Generic) Stop() error {
 	log.Info().Msgf("Stopping %s", strings.Title(service.Name()))
-	// send a kill signal to the process
-	process, _ := os.FindProcess(service.Pid)
-	err := process.Signal(os.Signal(os.Interrupt))
-	if err != nil && err.Error() != "os: process already finished" && err.Error() != "os: process not initialized" {
-		log.Error().Err(err).Msgf("Could not stop %s (pid %s)", strings.Title(service.Name()), service.Pid)
+	if !service.Running() {
+		log.Info().Msgf("%s is not running", strings.Title(service.Name()))
+		return nil
+	}
+
+	process, err := os.FindProcess(service.Pid)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not stop %s (pid %d)", strings.Title(service.Name()), service.Pid)
 		return err
 	}
+	grace := time.Duration(service.StopTimeout()) * time.Second
 
-	log.Info().Msg("Stopped")
-	return nil
+	for _, signal := range []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGKILL} {
+		err = process.Signal(signal)
+		if err != nil && err.Error() != "os: process already finished" {
+			log.Error().Err(err).Msgf("Could not stop %s (pid %d)", strings.Title(service.Name()), service.Pid)
+			return err
+		}
+		if service.waitForExit(grace) {
+			log.Info().Msgf("Stopped %s (%s)", strings.Title(service.Name()), signal)
+			return nil
+		}
+		log.Warn().Msgf("%s did not exit within %s of %s", strings.Title(service.Name()), grace, signal)
+	}
+
+	err = fmt.Errorf("%s (pid %d) is still running", strings.Title(service.Name()), service.Pid)
+	log.Error().Err(err).Msgf("Could not stop %s", strings.Title(service.Name()))
+	return err
+}
+
+// waitForExit will wait up to timeout for the service process to exit and return true if it did
+func (service *Generic) waitForExit(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !service.Running() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
 }
 
 // Restart will stop the service for this environment and then restart it.
@@ -226,9 +316,5 @@ func (service *Generic) Restart() error {
 		return err
 	}
 
-	service.Start()
-	if err != nil {
-		return err
-	}
-	return nil
+	return service.Start()
 }
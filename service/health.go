@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HealthReason describes why a service is not healthy
+type HealthReason string
+
+// the reasons a service can be unhealthy
+const (
+	ReasonProcessExited  HealthReason = "process exited"
+	ReasonAPIUnreachable HealthReason = "API unreachable"
+	ReasonSealed         HealthReason = "sealed"
+	ReasonStandby        HealthReason = "standby"
+	ReasonNotInitialized HealthReason = "not initialized"
+	ReasonUnhealthy      HealthReason = "unhealthy"
+	ReasonCanceled       HealthReason = "canceled"
+)
+
+// the bounds of the backoff between health checks
+const (
+	initialBackoff = 250 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// HealthError is returned when a service is not healthy
+type HealthError struct {
+	Service string
+	Reason  HealthReason
+	Err     error
+}
+
+// Error will return a description of why the service is not healthy
+func (healthErr *HealthError) Error() string {
+	if healthErr.Err != nil {
+		return fmt.Sprintf("%s %s: %s", strings.Title(healthErr.Service), healthErr.Reason, healthErr.Err)
+	}
+	return fmt.Sprintf("%s %s", strings.Title(healthErr.Service), healthErr.Reason)
+}
+
+// Unhealthy is a convenience function that probes can use to build a HealthError
+func (service *Generic) Unhealthy(reason HealthReason, err error) *HealthError {
+	return &HealthError{Service: service.Name(), Reason: reason, Err: err}
+}
+
+// Reason will return the reason a service is unhealthy from an error returned by a health check.  Errors that did not come
+// from a health check are reported as ReasonUnhealthy.
+func Reason(err error) HealthReason {
+	if healthErr, ok := err.(*HealthError); ok {
+		return healthErr.Reason
+	}
+	return ReasonUnhealthy
+}
+
+// Probe checks the service specific health of a service.  It returns nil if the service is healthy and an error (ideally a
+// HealthError) describing the problem if it is not.
+type Probe func() error
+
+// WaitHealthy will poll the service with probe until it reports healthy, the healthy timeout expires, the process exits or
+// the context is canceled.  The time between probes backs off exponentially.  If probe is nil only the process is checked.
+func (service *Generic) WaitHealthy(ctx context.Context, probe Probe) (bool, error) {
+	deadline := time.Now().Add(time.Duration(service.HealthyTimeout()) * time.Second)
+	backoff := initialBackoff
+
+	for {
+		// there is no point in waiting on a process that is no longer there
+		if !service.Running() {
+			return false, service.Unhealthy(ReasonProcessExited, nil)
+		}
+
+		// then see if it thinks it is healthy
+		var err error
+		if probe != nil {
+			err = probe()
+		}
+		if err == nil {
+			return true, nil
+		}
+
+		// if we are out of time we report the last problem we saw
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			cause := err
+			if healthErr, ok := err.(*HealthError); ok && healthErr.Err != nil {
+				cause = healthErr.Err
+			}
+			return false, &HealthError{
+				Service: service.Name(),
+				Reason:  Reason(err),
+				Err:     fmt.Errorf("timeout of %ds exceeded: %s", service.HealthyTimeout(), cause),
+			}
+		}
+
+		// otherwise we wait a bit and try again
+		if backoff > remaining {
+			backoff = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return false, service.Unhealthy(ReasonCanceled, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// the size of the chunks we read when searching backwards through a log file
+const tailChunkSize = 4096
+
+// TailLog will return the last count lines of the service's log file
+func (service *Generic) TailLog(count int) ([]string, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("can not show %d lines of a log", count)
+	}
+	file, err := os.Open(service.Logfile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// read backwards through the file until we have enough lines
+	var content []byte
+	offset := info.Size()
+	for offset > 0 && bytes.Count(content, []byte("\n")) <= count {
+		size := int64(tailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size)
+		_, err = file.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		content = append(chunk, content...)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}, nil
+	}
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	return lines, nil
+}
+
+// FollowLog will write the service's log file to out as it grows until done is closed.  Output starts at the current end of
+// the file.
+func (service *Generic) FollowLog(out io.Writer, done <-chan struct{}) error {
+	file, err := os.Open(service.Logfile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	for {
+		// if the file was truncated (e.g. the service was restarted) we start again from the top
+		info, err := os.Stat(service.Logfile)
+		if err == nil && info.Size() < offset {
+			offset, err = file.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+		}
+
+		written, err := io.Copy(out, file)
+		if err != nil {
+			return err
+		}
+		offset += written
+
+		select {
+		case <-done:
+			return nil
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// the number of times we will try to find a block of free ports before giving up
+const portAttempts = 50
+
+// reservedPorts keeps track of the ports we have handed out in this run so two services never get the same one
+var (
+	reservedPorts = make(map[int]bool)
+	portLock      sync.Mutex
+)
+
+// ReserveWorkspacePorts will reserve the ports recorded in the state files of the services in another workspace so that
+// we don't hand them out again while that workspace's services are stopped
+func ReserveWorkspacePorts(workspace string) {
+	stateFiles, err := filepath.Glob(filepath.Join(workspace, "*.yml"))
+	if err != nil {
+		return
+	}
+	for _, stateFile := range stateFiles {
+		content, err := ioutil.ReadFile(stateFile)
+		if err != nil {
+			continue
+		}
+		// some services keep their generic state at the top of the file and some nest it
+		var state struct {
+			Ports   map[string]int `yaml:"ports"`
+			Generic struct {
+				Ports map[string]int `yaml:"ports"`
+			} `yaml:"generic"`
+		}
+		if yaml.Unmarshal(content, &state) != nil {
+			continue
+		}
+		for _, port := range state.Ports {
+			reservePort(port)
+		}
+		for _, port := range state.Generic.Ports {
+			reservePort(port)
+		}
+	}
+}
+
+// AllocatePorts will make sure that each of the named ports has been assigned a free port on the loopback interface.
+// Ports that have already been assigned (e.g. read in from an existing workspace) are left alone.
+func (service *Generic) AllocatePorts(names ...string) error {
+	if service.Ports == nil {
+		service.Ports = make(map[string]int)
+	}
+	for _, name := range names {
+		if service.Ports[name] != 0 {
+			reservePort(service.Ports[name])
+			continue
+		}
+		ports, err := freePorts(1)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not allocate a %s port for %s", name, strings.Title(service.Name()))
+			return err
+		}
+		service.Ports[name] = ports[0]
+	}
+	return nil
+}
+
+// AllocateContiguousPorts works like AllocatePorts but makes sure that the named ports are sequential.  This is needed
+// for services that derive some of their ports from others (e.g. vault in dev mode uses <api port>+1 for its cluster port).
+// If any of the ports have already been assigned they are all left alone.
+func (service *Generic) AllocateContiguousPorts(names ...string) error {
+	if service.Ports == nil {
+		service.Ports = make(map[string]int)
+	}
+	assigned := true
+	for _, name := range names {
+		if service.Ports[name] == 0 {
+			assigned = false
+		}
+	}
+	if assigned {
+		for _, name := range names {
+			reservePort(service.Ports[name])
+		}
+		return nil
+	}
+
+	ports, err := freePorts(len(names))
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not allocate ports for %s", strings.Title(service.Name()))
+		return err
+	}
+	for i, name := range names {
+		service.Ports[name] = ports[i]
+	}
+	return nil
+}
+
+// Port will return the port that has been assigned to the named port
+func (service *Generic) Port(name string) int {
+	return service.Ports[name]
+}
+
+// reservePort marks a port as in use so that we don't hand it out again
+func reservePort(port int) {
+	portLock.Lock()
+	defer portLock.Unlock()
+	reservedPorts[port] = true
+}
+
+// freePorts will find a block of count sequential ports on the loopback interface that are not currently in use
+func freePorts(count int) ([]int, error) {
+	portLock.Lock()
+	defer portLock.Unlock()
+
+	for attempt := 0; attempt < portAttempts; attempt++ {
+		// let the OS pick our first port for us
+		first, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		listeners := []net.Listener{first}
+		start := first.Addr().(*net.TCPAddr).Port
+
+		// then make sure the rest of the block is free as well
+		ok := !reservedPorts[start]
+		for port := start + 1; ok && port < start+count; port++ {
+			listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+			if err != nil || reservedPorts[port] {
+				ok = false
+				if listener != nil {
+					listener.Close()
+				}
+				break
+			}
+			listeners = append(listeners, listener)
+		}
+		// we hold on to all of our listeners until we are done so the OS doesn't give us the same port twice
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		if !ok {
+			continue
+		}
+
+		ports := make([]int, count)
+		for i := range ports {
+			ports[i] = start + i
+			reservedPorts[ports[i]] = true
+		}
+		return ports, nil
+	}
+	return nil, fmt.Errorf("could not find %d free ports after %d attempts", count, portAttempts)
+}
@@ -1,13 +1,32 @@
 package service
 
+import (
+	"context"
+	"io"
+)
+
 // SupportService is the interface that a support service for the environment must implement
 type SupportService interface {
+	Base() *Generic
 	Init() error
 	Download() error
-	Healthy() (bool, error)
+	ReleasesURL() string
+	SetReleasesURL(string)
+	SigningKey() string
+	SetSigningKey(string)
+	SetCache(*Cache)
+	BinaryPath() string
+	SetBinaryPath(string)
+	Healthy(context.Context) (bool, error)
 	Read() (bool, error)
+	Exists() bool
+	VersionChanged() bool
+	PreviousVersion() string
+	RestartReason() string
+	RequireRestart(string)
 	Write() error
 	WriteServiceConfig() error
+	ConfigChanged() bool
 	Start() error
 	Stop() error
 	Restart() error
@@ -19,4 +38,14 @@ type SupportService interface {
 	SetServiceConfig(string)
 	HealthyTimeout() int
 	SetHealthyTimeout(int)
+	StopTimeout() int
+	SetStopTimeout(int)
+	TailLog(int) ([]string, error)
+	FollowLog(io.Writer, <-chan struct{}) error
+}
+
+// Bootstrapper is implemented by support services that need to be set up each time they start (e.g. vault needs to be
+// initialized and unsealed when it is not running in dev mode)
+type Bootstrapper interface {
+	Bootstrap(context.Context) error
 }
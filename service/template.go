@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+)
+
+// ConfigData is the data that is made available to service config templates
+type ConfigData struct {
+	Name      string
+	Address   string
+	Ports     map[string]int
+	Datadir   string
+	Workspace string
+	Logfile   string
+	// service specific values such as the addresses of other services and tokens
+	Vars map[string]string
+}
+
+// SetConfigVar will set a service specific value that is made available to the service config template as .Vars.<key>
+func (service *Generic) SetConfigVar(key, value string) {
+	if service.configVars == nil {
+		service.configVars = make(map[string]string)
+	}
+	service.configVars[key] = value
+}
+
+// ConfigData will return the values that are made available to the service config template
+func (service *Generic) ConfigData() ConfigData {
+	vars := service.configVars
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	return ConfigData{
+		Name:      service.Name(),
+		Address:   service.Address,
+		Ports:     service.Ports,
+		Datadir:   service.Datadir,
+		Workspace: service.Workspace(),
+		Logfile:   service.Logfile,
+		Vars:      vars,
+	}
+}
+
+// RenderServiceConfig will render the service config template for this service
+func (service *Generic) RenderServiceConfig() ([]byte, error) {
+	tmpl, err := template.New(service.ServiceConfigName).Option("missingkey=error").Parse(service.ServiceConfig())
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, service.ConfigData())
+	if err != nil {
+		return nil, err
+	}
+	return rendered.Bytes(), nil
+}
+
+// ConfigChanged will return true if the rendered service config differs from the one that was written when the
+// service was last started
+func (service *Generic) ConfigChanged() bool {
+	current, err := ioutil.ReadFile(filepath.Join(service.Datadir, service.ServiceConfigName))
+	if err != nil {
+		return true
+	}
+	rendered, err := service.RenderServiceConfig()
+	if err != nil {
+		return true
+	}
+	return !bytes.Equal(current, rendered)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	version "github.com/hashicorp/go-version"
+)
+
+// SetVersion will set the version of the service that we want to run.  If an existing instance in the workspace was
+// recorded with a different version VersionChanged will report true.
+func (service *Generic) SetVersion(requested string) {
+	if service.Version != "" && service.Version != requested {
+		service.previousVersion = service.Version
+		service.RequireRestart(fmt.Sprintf("requested version %s differs from the running version %s", requested, service.Version))
+	}
+	service.Version = requested
+}
+
+// RequireRestart will mark an existing instance of the service as needing a restart to pick up a change in its setup
+func (service *Generic) RequireRestart(reason string) {
+	service.restartReason = reason
+}
+
+// RestartReason will return why an existing instance of the service needs to be restarted, or an empty string if it
+// doesn't
+func (service *Generic) RestartReason() string {
+	return service.restartReason
+}
+
+// VersionChanged will return true if the version we want to run differs from the version recorded for the existing
+// instance in the workspace
+func (service *Generic) VersionChanged() bool {
+	return service.previousVersion != ""
+}
+
+// PreviousVersion will return the version recorded for the existing instance in the workspace if it differs from the
+// version we want to run
+func (service *Generic) PreviousVersion() string {
+	return service.previousVersion
+}
+
+// VersionAtLeast will return true if the version of the service is at least minimum.  This allows services to select
+// behavior that is only available in some versions.
+func (service *Generic) VersionAtLeast(minimum string) bool {
+	current, err := version.NewVersion(service.Version)
+	if err != nil {
+		return false
+	}
+	constraint, err := version.NewConstraint(">= " + minimum)
+	if err != nil {
+		return false
+	}
+	return constraint.Check(current)
+}
+
+// Exists will return true if there is an existing instance of this service recorded in the workspace
+func (service *Generic) Exists() bool {
+	_, err := os.Stat(filepath.Join(service.Workspace(), service.Name()+".yml"))
+	return err == nil
+}
@@ -0,0 +1,41 @@
+package utility
+
+import (
+	"sort"
+)
+
+// the ways that a key can differ between the data we have and the data we want
+const (
+	Added   = "added"
+	Changed = "changed"
+	Removed = "removed"
+)
+
+// Change is a single key that differs between the data we have and the data we want
+type Change struct {
+	Key    string
+	Action string
+	Old    string
+	New    string
+}
+
+// Diff will compare the data we have (current) with the data we want (wanted) and return the keys that differ, sorted
+// by key
+func Diff(current, wanted map[string]string) []Change {
+	changes := []Change{}
+	for key, value := range wanted {
+		old, ok := current[key]
+		if !ok {
+			changes = append(changes, Change{Key: key, Action: Added, New: value})
+		} else if old != value {
+			changes = append(changes, Change{Key: key, Action: Changed, Old: old, New: value})
+		}
+	}
+	for key, value := range current {
+		if _, ok := wanted[key]; !ok {
+			changes = append(changes, Change{Key: key, Action: Removed, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
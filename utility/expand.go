@@ -0,0 +1,251 @@
+package utility
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yamlv2 "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DataOptions control how a data file is read and loaded
+type DataOptions struct {
+	// the environment whose block is merged over the default block.  Maps in the environment block are merged key by
+	// key with the default block and anything else replaces what is there.  If it is empty only the default block is
+	// used.
+	Environment string
+	// the values that can be referenced as ${name} in the data file.  Environment variables can be referenced as
+	// ${env.NAME}.
+	Vars map[string]string
+	// how lists are flattened (EncodeExplode if it is empty)
+	Encoding string
+	// the prefix in consul that data is loaded under (e.g. app/<app>/<hash label>/)
+	KeyPrefix string
+	// the path in vault that secrets are loaded under.  Keys in secrets files are relative to it, and if it is empty they
+	// are relative to the root of vault.
+	SecretPath string
+	// when loading, remove anything that was loaded for the application before but is no longer in the data file
+	Prune bool
+}
+
+// SecretMarker is the key that a map tagged !secret is given.  Once a file has been flattened it marks the maps that are
+// stored as a single secret with a field for each of their keys.
+const SecretMarker = "\x00terrarium:secret"
+
+// the deepest we will follow includes before deciding that there is a loop
+const maxIncludeDepth = 16
+
+// ${name} references, and $${ which is a literal ${
+var referencePattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// Read will read a data file and parse it into data.  Any !include and !file tags are resolved relative to the
+// directory of the file and any references are expanded.
+func (data *YamlData) Read(dataFile string) error {
+	document, err := readDocument(dataFile)
+	if err == nil {
+		var rawData interface{}
+		rawData, err = data.decode(document, "", filepath.Dir(dataFile), 0)
+		if err == nil {
+			err = data.load(rawData)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", dataFile, err)
+	}
+	return nil
+}
+
+// readDocument will read and parse a yaml file
+func readDocument(file string) (*yaml.Node, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	return &document, err
+}
+
+// decode will turn a parsed yaml node into plain values, resolving any tags and references in it.  path is the
+// flattened key of node and is used in errors, and dir is the directory that files are relative to.
+func (data *YamlData) decode(node *yaml.Node, path, dir string, depth int) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		// an empty file has no content at all
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return data.decode(node.Content[0], path, dir, depth)
+	case yaml.AliasNode:
+		return data.decode(node.Alias, path, dir, depth)
+	case 0:
+		return nil, nil
+	}
+
+	switch node.Tag {
+	case "!file":
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s: !file needs the name of a file", path)
+		}
+		content, err := ioutil.ReadFile(relativeTo(dir, node.Value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return string(content), nil
+	case "!include":
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s: !include needs the name of a file", path)
+		}
+		file := relativeTo(dir, node.Value)
+		if depth >= maxIncludeDepth {
+			return nil, fmt.Errorf("%s: includes are nested more than %d deep (is %s including itself?)", path, maxIncludeDepth, file)
+		}
+		document, err := readDocument(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: could not include %s: %s", path, file, err)
+		}
+		// anything in the included file is relative to that file
+		return data.decode(document, path, filepath.Dir(file), depth+1)
+	case "!secret":
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: !secret can only be used on a map", path)
+		}
+	default:
+		if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+			return nil, fmt.Errorf("%s: unknown tag %s", path, node.Tag)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		return data.decodeMap(node, path, dir, depth)
+	case yaml.SequenceNode:
+		decoded := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			value, err := data.decode(item, keyPath(path, strconv.Itoa(i)), dir, depth)
+			if err != nil {
+				return nil, err
+			}
+			decoded[i] = value
+		}
+		return decoded, nil
+	}
+
+	value, err := scalarValue(node)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if text, ok := value.(string); ok {
+		return data.expandReferences(text, path)
+	}
+	return value, nil
+}
+
+// decodeMap will turn a mapping node into a map.  Maps that are merged in with << go in first so that the keys of this
+// map win, and maps tagged !secret are given a SecretMarker key.
+func (data *YamlData) decodeMap(node *yaml.Node, path, dir string, depth int) (interface{}, error) {
+	decoded := make(map[interface{}]interface{})
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() != "!!merge" {
+			continue
+		}
+		merged, err := data.decode(node.Content[i+1], keyPath(path, "<<"), dir, depth)
+		if err != nil {
+			return nil, err
+		}
+		// a list of maps is merged with the earlier maps winning
+		maps, ok := merged.([]interface{})
+		if !ok {
+			maps = []interface{}{merged}
+		}
+		for j := len(maps) - 1; j >= 0; j-- {
+			mergedMap, ok := maps[j].(map[interface{}]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: can only merge a map", keyPath(path, "<<"))
+			}
+			for key, value := range mergedMap {
+				decoded[key] = value
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() == "!!merge" {
+			continue
+		}
+		// keys are used as they are written since they become paths in consul and vault (e.g. on stays on and is not true)
+		if node.Content[i].Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s: map keys must be simple values", path)
+		}
+		key := node.Content[i].Value
+		value, err := data.decode(node.Content[i+1], keyPath(path, key), dir, depth)
+		if err != nil {
+			return nil, err
+		}
+		decoded[key] = value
+	}
+
+	if node.Tag == "!secret" {
+		decoded[SecretMarker] = true
+	}
+	return decoded, nil
+}
+
+// scalarValue will return the value of a scalar node.  Quoted scalars are strings and explicitly tagged ones are what
+// their tag says.  Plain scalars are resolved the way yaml 1.1 does it (e.g. yes is true) since that is how data files
+// have always been read.
+func scalarValue(node *yaml.Node) (interface{}, error) {
+	var value interface{}
+	if node.Style != 0 {
+		err := node.Decode(&value)
+		return value, err
+	}
+	if yamlv2.Unmarshal([]byte(node.Value), &value) != nil {
+		return node.Value, nil
+	}
+	return value, nil
+}
+
+// relativeTo will return the path of file relative to dir unless it is absolute
+func relativeTo(dir, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+// expandReferences will replace any ${name} references in value
+func (data *YamlData) expandReferences(value, path string) (string, error) {
+	var err error
+	expanded := referencePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		name := referencePattern.FindStringSubmatch(match)[1]
+		if strings.HasPrefix(name, "env.") {
+			if resolved, ok := os.LookupEnv(strings.TrimPrefix(name, "env.")); ok {
+				return resolved
+			}
+		} else if resolved, ok := data.Vars[name]; ok {
+			return resolved
+		}
+		if err == nil {
+			err = fmt.Errorf("%s: unresolved reference ${%s}", path, name)
+		}
+		return match
+	})
+	return expanded, err
+}
+
+// keyPath will add key to a flattened key path
+func keyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
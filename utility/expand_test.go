@@ -0,0 +1,50 @@
+package utility
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeysAreLiteral(t *testing.T) {
+	content := `
+default:
+  flags:
+    y: 1
+    n: 2
+    on: 3
+    off: 4
+    yes: 5
+    no: 6
+    ~: 7
+    1.0: 8
+  enabled: on
+  disabled: off
+`
+	want := map[string]string{
+		"flags/y":   "1",
+		"flags/n":   "2",
+		"flags/on":  "3",
+		"flags/off": "4",
+		"flags/yes": "5",
+		"flags/no":  "6",
+		"flags/~":   "7",
+		"flags/1.0": "8",
+		// values are still resolved the way yaml 1.1 does it
+		"enabled":  "true",
+		"disabled": "false",
+	}
+	data, err := readData(t, "consul", "", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(map[string]string(data.Records), want) {
+		t.Errorf("records are %v, not %v", data.Records, want)
+	}
+}
+
+func TestComplexKeys(t *testing.T) {
+	_, err := readData(t, "consul", "", "default:\n  ? [a, b]\n  : 1\n")
+	if err == nil {
+		t.Error("a list was accepted as a map key")
+	}
+}
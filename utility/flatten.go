@@ -3,71 +3,188 @@ package utility
 // lifted and modified from here: https://github.com/hashicorp/terraform/blob/master/flatmap/flatten.go
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
+	"time"
+)
+
+// the ways that lists can be flattened
+const (
+	// EncodeExplode turns each item of a list into its own key along with a # key that holds the length of the list
+	EncodeExplode = "explode"
+	// EncodeJSON turns a list into a single key that holds the list as json
+	EncodeJSON = "json"
 )
 
 // Flatten takes a structure and turns into a flat map[string]string.
 //
 // Within the "thing" parameter, only primitive values are allowed. Structs are
 // not supported. Therefore, it can only be slices, maps, primitives, and
-// any combination of those together.
+// any combination of those together.  Maps become nested keys, and lists are
+// flattened according to encoding (EncodeExplode if it is empty).
 //
-// See the tests for examples of what inputs are turned into.
-func Flatten(thing map[interface{}]interface{}) Map {
+// An error naming the key is returned for anything that can't be flattened.
+func Flatten(thing map[interface{}]interface{}, encoding string) (Map, error) {
 	result := make(map[string]string)
+	if encoding == "" {
+		encoding = EncodeExplode
+	}
+	if encoding != EncodeExplode && encoding != EncodeJSON {
+		return nil, fmt.Errorf("unknown encoding %s: must be %s or %s", encoding, EncodeExplode, EncodeJSON)
+	}
 
 	for k, raw := range thing {
-		flatten(result, k.(string), reflect.ValueOf(raw))
+		key, err := keyString("", k)
+		if err != nil {
+			return nil, err
+		}
+		err = flatten(result, key, reflect.ValueOf(raw), encoding)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return Map(result)
+	return Map(result), nil
 }
 
-func flatten(result map[string]string, prefix string, v reflect.Value) {
+func flatten(result map[string]string, prefix string, v reflect.Value, encoding string) error {
 	if v.Kind() == reflect.Interface {
 		v = v.Elem()
 	}
 
+	// a null value has nothing in it
+	if !v.IsValid() {
+		result[prefix] = ""
+		return nil
+	}
+
 	switch v.Kind() {
-	case reflect.Bool:
-		if v.Bool() {
-			result[prefix] = "true"
-		} else {
-			result[prefix] = "false"
-		}
-	case reflect.Int:
-		result[prefix] = fmt.Sprintf("%d", v.Int())
 	case reflect.Map:
-		flattenMap(result, prefix, v)
-	case reflect.Slice:
-		flattenSlice(result, prefix, v)
+		return flattenMap(result, prefix, v, encoding)
+	case reflect.Slice, reflect.Array:
+		if encoding == EncodeJSON {
+			encoded, err := encodeJSON(prefix, v.Interface())
+			if err != nil {
+				return err
+			}
+			result[prefix] = encoded
+			return nil
+		}
+		return flattenSlice(result, prefix, v, encoding)
+	}
+
+	value, err := flattenScalar(prefix, v)
+	if err != nil {
+		return err
+	}
+	result[prefix] = value
+	return nil
+}
+
+// flattenScalar will turn a single value into a string
+func flattenScalar(prefix string, v reflect.Value) (string, error) {
+	if timestamp, ok := v.Interface().(time.Time); ok {
+		return timestamp.Format(time.RFC3339Nano), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
 	case reflect.String:
-		result[prefix] = v.String()
-	default:
-		panic(fmt.Sprintf("Unknown: %s", v))
+		return v.String(), nil
 	}
+	return "", fmt.Errorf("%s: can not flatten a value of type %s", prefix, v.Type())
 }
 
-func flattenMap(result map[string]string, prefix string, v reflect.Value) {
+// keyString will turn a map key into a string.  prefix is the key of the map and is used in errors.
+func keyString(prefix string, k interface{}) (string, error) {
+	v := reflect.ValueOf(k)
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", fmt.Errorf("%s: map keys can not be null", keyPath(prefix, "~"))
+	}
+	if v.Kind() == reflect.Map || v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return "", fmt.Errorf("%s: map keys must be simple values, not a %s", keyPath(prefix, fmt.Sprintf("%v", k)), v.Kind())
+	}
+	return flattenScalar(keyPath(prefix, fmt.Sprintf("%v", k)), v)
+}
+
+func flattenMap(result map[string]string, prefix string, v reflect.Value, encoding string) error {
 	for _, k := range v.MapKeys() {
-		if k.Kind() == reflect.Interface {
-			k = k.Elem()
+		key, err := keyString(prefix, k.Interface())
+		if err != nil {
+			return err
 		}
-
-		if k.Kind() != reflect.String {
-			panic(fmt.Sprintf("%s: map key is not string: %s", prefix, k))
+		err = flatten(result, keyPath(prefix, key), v.MapIndex(k), encoding)
+		if err != nil {
+			return err
 		}
-
-		flatten(result, fmt.Sprintf("%s/%s", prefix, k.String()), v.MapIndex(k))
 	}
+	return nil
 }
 
-func flattenSlice(result map[string]string, prefix string, v reflect.Value) {
+func flattenSlice(result map[string]string, prefix string, v reflect.Value, encoding string) error {
 	prefix = prefix + "/"
 
 	result[prefix+"#"] = fmt.Sprintf("%d", v.Len())
 	for i := 0; i < v.Len(); i++ {
-		flatten(result, fmt.Sprintf("%s%d", prefix, i), v.Index(i))
+		err := flatten(result, fmt.Sprintf("%s%d", prefix, i), v.Index(i), encoding)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeJSON will encode a value as json.  yaml gives us maps with interface{} keys, which json can't encode, so we
+// convert them first.
+func encodeJSON(prefix string, value interface{}) (string, error) {
+	converted, err := jsonCompatible(prefix, value)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(converted)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", prefix, err)
+	}
+	return string(encoded), nil
+}
+
+// jsonCompatible will convert any maps in value into maps with string keys
+func jsonCompatible(prefix string, value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{})
+		for k, v := range typed {
+			key, err := keyString(prefix, k)
+			if err != nil {
+				return nil, err
+			}
+			converted[key], err = jsonCompatible(keyPath(prefix, key), v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(typed))
+		for i, v := range typed {
+			var err error
+			converted[i], err = jsonCompatible(keyPath(prefix, strconv.Itoa(i)), v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
 	}
+	return value, nil
 }
@@ -0,0 +1,188 @@
+package utility
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlatten(t *testing.T) {
+	cases := []struct {
+		name     string
+		thing    map[interface{}]interface{}
+		encoding string
+		want     map[string]string
+	}{
+		{
+			name: "scalars",
+			thing: map[interface{}]interface{}{
+				"string":   "text",
+				"int":      42,
+				"negative": int64(-7),
+				"uint":     uint(7),
+				"float":    1.5,
+				"whole":    2.0,
+				"big":      1e21,
+				"true":     true,
+				"false":    false,
+				"null":     nil,
+				"time":     time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC),
+			},
+			want: map[string]string{
+				"string":   "text",
+				"int":      "42",
+				"negative": "-7",
+				"uint":     "7",
+				"float":    "1.5",
+				"whole":    "2",
+				"big":      "1000000000000000000000",
+				"true":     "true",
+				"false":    "false",
+				"null":     "",
+				"time":     "2018-05-01T12:00:00Z",
+			},
+		},
+		{
+			name: "keys that are not strings",
+			thing: map[interface{}]interface{}{
+				1:    "one",
+				true: "yes",
+				1.5:  "one and a half",
+			},
+			want: map[string]string{"1": "one", "true": "yes", "1.5": "one and a half"},
+		},
+		{
+			name: "nested maps",
+			thing: map[interface{}]interface{}{
+				"db": map[interface{}]interface{}{
+					"host":    "localhost",
+					"options": map[interface{}]interface{}{"ssl": true, 3: "three"},
+				},
+			},
+			want: map[string]string{"db/host": "localhost", "db/options/ssl": "true", "db/options/3": "three"},
+		},
+		{
+			name: "nested lists exploded",
+			thing: map[interface{}]interface{}{
+				"matrix": []interface{}{
+					[]interface{}{1, 2},
+					[]interface{}{},
+					map[interface{}]interface{}{"name": "a", "ports": []interface{}{80, 443}},
+					nil,
+				},
+			},
+			encoding: EncodeExplode,
+			want: map[string]string{
+				"matrix/#":         "4",
+				"matrix/0/#":       "2",
+				"matrix/0/0":       "1",
+				"matrix/0/1":       "2",
+				"matrix/1/#":       "0",
+				"matrix/2/name":    "a",
+				"matrix/2/ports/#": "2",
+				"matrix/2/ports/0": "80",
+				"matrix/2/ports/1": "443",
+				"matrix/3":         "",
+			},
+		},
+		{
+			name: "explode is the default",
+			thing: map[interface{}]interface{}{
+				"hosts": []interface{}{"a"},
+			},
+			want: map[string]string{"hosts/#": "1", "hosts/0": "a"},
+		},
+		{
+			name: "nested lists as json",
+			thing: map[interface{}]interface{}{
+				"matrix": []interface{}{
+					[]interface{}{1, 2.5},
+					[]interface{}{},
+					map[interface{}]interface{}{"name": "a", "ports": []interface{}{80, 443}, 1: true},
+					nil,
+				},
+				"db": map[interface{}]interface{}{"hosts": []interface{}{"a", "b"}},
+			},
+			encoding: EncodeJSON,
+			want: map[string]string{
+				"matrix":   `[[1,2.5],[],{"1":true,"name":"a","ports":[80,443]},null]`,
+				"db/hosts": `["a","b"]`,
+			},
+		},
+	}
+	for _, c := range cases {
+		got, err := Flatten(c.thing, c.encoding)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(map[string]string(got), c.want) {
+			t.Errorf("%s: got %v, not %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFlattenErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		thing    map[interface{}]interface{}
+		encoding string
+		// what the error should mention
+		want string
+	}{
+		{
+			name:     "unknown encoding",
+			thing:    map[interface{}]interface{}{"a": "b"},
+			encoding: "xml",
+			want:     "unknown encoding xml",
+		},
+		{
+			name:  "struct",
+			thing: map[interface{}]interface{}{"db": map[interface{}]interface{}{"host": struct{}{}}},
+			want:  "db/host",
+		},
+		{
+			name:  "channel in a list",
+			thing: map[interface{}]interface{}{"hosts": []interface{}{"a", make(chan int)}},
+			want:  "hosts/1",
+		},
+		{
+			name:     "channel in a json list",
+			thing:    map[interface{}]interface{}{"hosts": []interface{}{"a", make(chan int)}},
+			encoding: EncodeJSON,
+			want:     "hosts",
+		},
+		{
+			name:  "null key",
+			thing: map[interface{}]interface{}{"db": map[interface{}]interface{}{nil: "a"}},
+			want:  "db/~",
+		},
+		{
+			name:  "null top level key",
+			thing: map[interface{}]interface{}{nil: "a"},
+			want:  "map keys can not be null",
+		},
+		{
+			name:  "list key",
+			thing: map[interface{}]interface{}{"db": map[interface{}]interface{}{[2]string{"a", "b"}: "a"}},
+			want:  "map keys must be simple values",
+		},
+		{
+			name:     "bad key in a json list",
+			thing:    map[interface{}]interface{}{"hosts": []interface{}{map[interface{}]interface{}{nil: "a"}}},
+			encoding: EncodeJSON,
+			want:     "hosts/0/~",
+		},
+	}
+	for _, c := range cases {
+		_, err := Flatten(c.thing, c.encoding)
+		if err == nil {
+			t.Errorf("%s: no error", c.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: error %q does not mention %q", c.name, err, c.want)
+		}
+	}
+}
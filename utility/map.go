@@ -34,7 +34,7 @@ func (m Map) Delete(prefix string) {
 				continue
 			}
 
-			if k[len(prefix):len(prefix)+1] != "." {
+			if k[len(prefix):len(prefix)+1] != "/" {
 				continue
 			}
 		}
@@ -47,7 +47,7 @@ func (m Map) Delete(prefix string) {
 func (m Map) Keys() []string {
 	ks := make(map[string]struct{})
 	for k := range m {
-		idx := strings.Index(k, ".")
+		idx := strings.Index(k, "/")
 		if idx == -1 {
 			idx = len(k)
 		}
@@ -76,7 +76,7 @@ func (m Map) Merge(m2 Map) {
 		m.Delete(prefix)
 
 		for k, v := range m2 {
-			if strings.HasPrefix(k, prefix) {
+			if k == prefix || strings.HasPrefix(k, prefix+"/") {
 				m[k] = v
 			}
 		}
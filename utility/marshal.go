@@ -0,0 +1,44 @@
+package utility
+
+import (
+	"bytes"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Marshal will write out data in the format of a data file.  Maps with a SecretMarker key (which Unflatten leaves for
+// multi-field secrets) are written with a !secret tag.
+func Marshal(data interface{}) ([]byte, error) {
+	var document yaml.Node
+	err := document.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+	tagSecrets(&document)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&document)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	return out.Bytes(), err
+}
+
+// tagSecrets will swap the SecretMarker keys under node for !secret tags
+func tagSecrets(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == SecretMarker {
+				node.Tag = "!secret"
+				node.Content = append(node.Content[:i], node.Content[i+2:]...)
+				break
+			}
+		}
+	}
+	for _, child := range node.Content {
+		tagSecrets(child)
+	}
+}
@@ -0,0 +1,130 @@
+package utility
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	cases := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{
+			name: "nested keys",
+			data: map[interface{}]interface{}{
+				"name": "app",
+				"db":   map[interface{}]interface{}{"host": "localhost", "port": "5432"},
+			},
+			want: "db:\n  host: localhost\n  port: \"5432\"\nname: app\n",
+		},
+		{
+			name: "list",
+			data: map[interface{}]interface{}{"hosts": []interface{}{"a", "b"}},
+			want: "hosts:\n  - a\n  - b\n",
+		},
+		{
+			name: "secret",
+			data: map[interface{}]interface{}{
+				"db": map[interface{}]interface{}{"username": "app", "password": "hunter2", SecretMarker: "true"},
+			},
+			want: "db: !secret\n  password: hunter2\n  username: app\n",
+		},
+		{
+			name: "secret in a list",
+			data: map[interface{}]interface{}{
+				"dbs": []interface{}{map[interface{}]interface{}{"username": "app", SecretMarker: "true"}},
+			},
+			want: "dbs:\n  - !secret\n    username: app\n",
+		},
+	}
+	for _, c := range cases {
+		got, err := Marshal(c.data)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("%s: got\n%s\nnot\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+// TestExportRoundTrip checks that records that are exported the way the export command does it are read back in as the
+// same records
+func TestExportRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		dataType string
+		records  map[string]string
+		secrets  map[string]bool
+	}{
+		{
+			name:     "data",
+			dataType: "consul",
+			records: map[string]string{
+				"name":           "app",
+				"port":           "8080",
+				"enabled":        "true",
+				"switch":         "on",
+				"empty":          "",
+				"db/host":        "localhost",
+				"db/options/ssl": "false",
+				"hosts/#":        "2",
+				"hosts/0":        "a",
+				"hosts/1":        "b",
+				"none/#":         "0",
+				"servers/#":      "1",
+				"servers/0/name": "a",
+				"servers/0/port": "80",
+			},
+			secrets: map[string]bool{},
+		},
+		{
+			name:     "secrets",
+			dataType: "vault",
+			records: map[string]string{
+				"api_key":            "abc123",
+				"db/username":        "app",
+				"db/password":        "hunter2",
+				"other/password":     "swordfish",
+				"certs/#":            "1",
+				"certs/0/key":        "-----BEGIN KEY-----\nabc\n-----END KEY-----\n",
+				"certs/0/passphrase": "yes",
+			},
+			secrets: map[string]bool{"db": true, "certs/0": true},
+		},
+	}
+	for _, c := range cases {
+		// exports hold the marker keys of the secrets along with the records
+		exported := make(map[string]string)
+		for key, value := range c.records {
+			exported[key] = value
+		}
+		for key := range c.secrets {
+			exported[key+"/"+SecretMarker] = "true"
+		}
+		var data interface{} = Unflatten(exported)
+		if c.dataType == "consul" {
+			data = map[string]interface{}{"default": data}
+		}
+		content, err := Marshal(data)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+
+		read, err := readData(t, c.dataType, "", string(content))
+		if err != nil {
+			t.Errorf("%s: %s\n%s", c.name, err, content)
+			continue
+		}
+		if !reflect.DeepEqual(map[string]string(read.Records), c.records) {
+			t.Errorf("%s: records are %v, not %v\n%s", c.name, read.Records, c.records, content)
+		}
+		if !reflect.DeepEqual(read.Secrets, c.secrets) {
+			t.Errorf("%s: secrets are %v, not %v\n%s", c.name, read.Secrets, c.secrets, content)
+		}
+	}
+}
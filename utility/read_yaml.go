@@ -1,44 +1,106 @@
 package utility
 
 import (
-	"strings"
+	"fmt"
+	"path"
 )
 
 // YamlData stores a representation of our config data in a fashion that it can be easily added to consul or vault
 type YamlData struct {
 	Records map[string]string
+	// the keys of the maps that were tagged !secret.  Each of them is stored in vault as a single secret with a field for
+	// each of the records under it.
+	Secrets map[string]bool
 	// either consul or vault.  This impacts how we format the data
 	DataType string
+	DataOptions
 }
 
-// UnmarshalYAML allows for custom unmarshaling based on the destination of the data
-func (data *YamlData) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var rawData interface{}
-	var newKey string
+// load will turn the parsed content of a data file into records in a fashion that suits the destination of the data
+func (data *YamlData) load(rawData interface{}) error {
 	// create a new map
 	data.Records = make(map[string]string)
+	data.Secrets = make(map[string]bool)
 
-	// parse the data into a variable
-	unmarshal(&rawData)
+	// an empty file has no data
+	if rawData == nil {
+		return nil
+	}
+	rawMap, ok := rawData.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("data must be a map of keys to values")
+	}
+	// run through and adjust our data ppropriately for the application.  Data files are always organized by environment
+	// but secrets files only are if they have a default block.
+	_, hasDefault := rawMap["default"]
+	if data.DataType == "consul" || (data.DataType == "vault" && hasDefault) {
+		var err error
+		rawMap, err = data.overlay(rawMap)
+		if err != nil {
+			return err
+		}
+	}
 	// flatten our data
-	flatmap := Flatten(rawData.(map[interface{}]interface{}))
-	// run through and adjust our data ppropriately for the application
-	switch data.DataType {
-	case "consul":
-		for key, value := range flatmap {
-			// adjust the key to match our conventions
-			keyParts := strings.SplitN(key, "/", 2)
-			if keyParts[0] == "default" {
-				newKey = keyParts[1]
-			} else {
-				newKey = keyParts[1] + "/" + keyParts[0]
+	flatmap, err := Flatten(rawMap, data.Encoding)
+	if err != nil {
+		return err
+	}
+	data.Records = flatmap
+	data.findSecrets()
+
+	return nil
+}
+
+// findSecrets will move the marker keys of multi-field secrets out of our records
+func (data *YamlData) findSecrets() {
+	for key := range data.Records {
+		if path.Base(key) != SecretMarker {
+			continue
+		}
+		delete(data.Records, key)
+		if dir := path.Dir(key); dir != "." {
+			data.Secrets[dir] = true
+		}
+	}
+}
+
+// overlay will merge the block for our environment over the default block of data that is organized by environment.
+// Maps are merged key by key, so an environment only needs to list the keys it changes, and anything else (including
+// lists) is replaced.  Every top level key must be an environment block.
+func (data *YamlData) overlay(rawMap map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	environments := make(map[string]map[interface{}]interface{})
+	for key, value := range rawMap {
+		name := fmt.Sprintf("%v", key)
+		block, ok := value.(map[interface{}]interface{})
+		if !ok {
+			if value != nil {
+				return nil, fmt.Errorf("%s: top level keys must be environment blocks (e.g. default), is it indented correctly?", name)
 			}
-			// then put the value in our struct
-			data.Records[newKey] = value
+			// an empty environment block has nothing in it
+			block = make(map[interface{}]interface{})
 		}
-	case "vault":
-		data.Records = flatmap
+		environments[name] = block
 	}
 
-	return nil
+	records := mergeMaps(make(map[interface{}]interface{}), environments["default"])
+	if overrides, ok := environments[data.Environment]; ok && data.Environment != "default" {
+		records = mergeMaps(records, overrides)
+	}
+	return records, nil
+}
+
+// mergeMaps will merge overrides into base and return it.  Maps that are in both are merged in turn, and everything else
+// in overrides replaces what is in base.
+func mergeMaps(base, overrides map[interface{}]interface{}) map[interface{}]interface{} {
+	for key, value := range overrides {
+		overrideMap, overrideIsMap := value.(map[interface{}]interface{})
+		baseMap, baseIsMap := base[key].(map[interface{}]interface{})
+		if overrideIsMap && baseIsMap {
+			// copy the base so that we never change the default block itself
+			base[key] = mergeMaps(mergeMaps(make(map[interface{}]interface{}), baseMap), overrideMap)
+			continue
+		}
+		base[key] = value
+	}
+	return base
 }
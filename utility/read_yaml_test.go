@@ -0,0 +1,135 @@
+package utility
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readData will write content to a data file and read it into a YamlData of dataType for environment
+func readData(t *testing.T, dataType, environment, content string) (*YamlData, error) {
+	dir, err := ioutil.TempDir("", "terrarium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dataFile := filepath.Join(dir, "data.yml")
+	if err := ioutil.WriteFile(dataFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := &YamlData{DataType: dataType, DataOptions: DataOptions{Environment: environment}}
+	return data, data.Read(dataFile)
+}
+
+func TestOverlay(t *testing.T) {
+	content := `
+default:
+  name: app
+  db:
+    host: db.example.com
+    port: 5432
+    options:
+      ssl: true
+      timeout: 10
+  hosts: [a, b, c]
+staging:
+  db:
+    host: staging-db.example.com
+    options:
+      timeout: 30
+  hosts: [d]
+  debug: true
+`
+	cases := []struct {
+		environment string
+		want        map[string]string
+	}{
+		{
+			environment: "",
+			want: map[string]string{
+				"name":               "app",
+				"db/host":            "db.example.com",
+				"db/port":            "5432",
+				"db/options/ssl":     "true",
+				"db/options/timeout": "10",
+				"hosts/#":            "3",
+				"hosts/0":            "a",
+				"hosts/1":            "b",
+				"hosts/2":            "c",
+			},
+		},
+		{
+			environment: "production",
+			want: map[string]string{
+				"name":               "app",
+				"db/host":            "db.example.com",
+				"db/port":            "5432",
+				"db/options/ssl":     "true",
+				"db/options/timeout": "10",
+				"hosts/#":            "3",
+				"hosts/0":            "a",
+				"hosts/1":            "b",
+				"hosts/2":            "c",
+			},
+		},
+		{
+			// nested maps are merged key by key and lists are replaced
+			environment: "staging",
+			want: map[string]string{
+				"name":               "app",
+				"db/host":            "staging-db.example.com",
+				"db/port":            "5432",
+				"db/options/ssl":     "true",
+				"db/options/timeout": "30",
+				"hosts/#":            "1",
+				"hosts/0":            "d",
+				"debug":              "true",
+			},
+		},
+	}
+	for _, c := range cases {
+		for _, dataType := range []string{"consul", "vault"} {
+			data, err := readData(t, dataType, c.environment, content)
+			if err != nil {
+				t.Errorf("%s %q: %s", dataType, c.environment, err)
+				continue
+			}
+			if !reflect.DeepEqual(map[string]string(data.Records), c.want) {
+				t.Errorf("%s %q: records are %v, not %v", dataType, c.environment, data.Records, c.want)
+			}
+		}
+	}
+}
+
+func TestOverlayStrayKey(t *testing.T) {
+	content := `
+default:
+  name: app
+timeout: 10
+`
+	for _, dataType := range []string{"consul", "vault"} {
+		_, err := readData(t, dataType, "", content)
+		if err == nil {
+			t.Errorf("%s: a key outside of an environment block was accepted", dataType)
+			continue
+		}
+		if !strings.Contains(err.Error(), "data.yml") || !strings.Contains(err.Error(), "timeout") {
+			t.Errorf("%s: error %q does not name the file and the key", dataType, err)
+		}
+	}
+}
+
+func TestVaultWithoutEnvironments(t *testing.T) {
+	data, err := readData(t, "vault", "staging", "name: app\ndb:\n  password: secret\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"name": "app", "db/password": "secret"}
+	if !reflect.DeepEqual(map[string]string(data.Records), want) {
+		t.Errorf("records are %v, not %v", data.Records, want)
+	}
+}
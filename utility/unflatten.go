@@ -0,0 +1,61 @@
+package utility
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Unflatten is the inverse of Flatten.  It turns flattened keys back into nested maps, and turns maps with a # entry
+// holding the length of a list back into lists.
+func Unflatten(flat map[string]string) map[interface{}]interface{} {
+	root := make(map[interface{}]interface{})
+	for key, value := range flat {
+		parts := strings.Split(key, "/")
+		node := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[interface{}]interface{})
+			if !ok {
+				child = make(map[interface{}]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		// a key can't hold both a value and other keys, so if it already has keys we keep those
+		if _, ok := node[parts[len(parts)-1]].(map[interface{}]interface{}); !ok {
+			node[parts[len(parts)-1]] = value
+		}
+	}
+	for key, value := range root {
+		root[key] = unflattenLists(value)
+	}
+	return root
+}
+
+// unflattenLists will turn any maps under value that were lists before they were flattened back into lists
+func unflattenLists(value interface{}) interface{} {
+	node, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return value
+	}
+	for key, child := range node {
+		node[key] = unflattenLists(child)
+	}
+
+	length, ok := node["#"].(string)
+	if !ok {
+		return node
+	}
+	count, err := strconv.Atoi(length)
+	if err != nil || len(node) != count+1 {
+		return node
+	}
+	list := make([]interface{}, count)
+	for i := range list {
+		item, ok := node[strconv.Itoa(i)]
+		if !ok {
+			return node
+		}
+		list[i] = item
+	}
+	return list
+}
@@ -0,0 +1,93 @@
+package utility
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnflatten(t *testing.T) {
+	cases := []struct {
+		name string
+		flat map[string]string
+		want map[interface{}]interface{}
+	}{
+		{
+			name: "empty",
+			flat: map[string]string{},
+			want: map[interface{}]interface{}{},
+		},
+		{
+			name: "nested keys",
+			flat: map[string]string{"name": "app", "db/host": "localhost", "db/options/ssl": "true"},
+			want: map[interface{}]interface{}{
+				"name": "app",
+				"db": map[interface{}]interface{}{
+					"host":    "localhost",
+					"options": map[interface{}]interface{}{"ssl": "true"},
+				},
+			},
+		},
+		{
+			name: "list",
+			flat: map[string]string{"hosts/#": "2", "hosts/0": "a", "hosts/1": "b"},
+			want: map[interface{}]interface{}{"hosts": []interface{}{"a", "b"}},
+		},
+		{
+			name: "empty list",
+			flat: map[string]string{"hosts/#": "0"},
+			want: map[interface{}]interface{}{"hosts": []interface{}{}},
+		},
+		{
+			name: "list of maps and lists",
+			flat: map[string]string{
+				"servers/#":        "2",
+				"servers/0/name":   "a",
+				"servers/0/port":   "80",
+				"servers/1/#":      "1",
+				"servers/1/0":      "b",
+				"servers/1/nested": "",
+			},
+			want: map[interface{}]interface{}{
+				"servers": []interface{}{
+					map[interface{}]interface{}{"name": "a", "port": "80"},
+					// an extra key means that this was a map with a # key rather than a list
+					map[interface{}]interface{}{"#": "1", "0": "b", "nested": ""},
+				},
+			},
+		},
+		{
+			name: "list with a missing item",
+			flat: map[string]string{"hosts/#": "2", "hosts/0": "a", "hosts/2": "c"},
+			want: map[interface{}]interface{}{
+				"hosts": map[interface{}]interface{}{"#": "2", "0": "a", "2": "c"},
+			},
+		},
+		{
+			name: "length that is not a number",
+			flat: map[string]string{"hosts/#": "many", "hosts/0": "a"},
+			want: map[interface{}]interface{}{
+				"hosts": map[interface{}]interface{}{"#": "many", "0": "a"},
+			},
+		},
+		{
+			name: "key with a value and keys under it",
+			flat: map[string]string{"db": "localhost", "db/port": "5432"},
+			want: map[interface{}]interface{}{
+				"db": map[interface{}]interface{}{"port": "5432"},
+			},
+		},
+		{
+			name: "secret marker",
+			flat: map[string]string{"db/username": "app", "db/" + SecretMarker: "true"},
+			want: map[interface{}]interface{}{
+				"db": map[interface{}]interface{}{"username": "app", SecretMarker: "true"},
+			},
+		},
+	}
+	for _, c := range cases {
+		got := Unflatten(c.flat)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, not %#v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package vault
+
+import (
+	"context"
+	"errors"
+
+	terrarium "github.com/dansteen/terrarium/service"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/rs/zerolog/log"
+)
+
+// errNoUnsealKeys is returned when vault stays sealed after we have used all the unseal keys recorded in the workspace
+var errNoUnsealKeys = errors.New("the unseal keys recorded in the workspace did not unseal vault")
+
+// Bootstrap will initialize vault the first time it starts and unseal it every time after that.  In dev mode vault
+// does this itself so there is nothing to do.
+func (service *Service) Bootstrap(ctx context.Context) error {
+	if service.Storage == StorageDev || service.Storage == "" {
+		return nil
+	}
+
+	// we need to be able to talk to vault before we can do anything else
+	_, err := service.WaitHealthy(ctx, service.reachable)
+	if err != nil {
+		return err
+	}
+
+	initialized, err := service.client.Sys().InitStatus()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not get the init status of vault")
+		return err
+	}
+	if !initialized {
+		log.Info().Msg("Initializing Vault")
+		// this is a development environment so a single unseal key is plenty
+		response, err := service.client.Sys().Init(&vault.InitRequest{
+			SecretShares:    1,
+			SecretThreshold: 1,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Could not initialize vault")
+			return err
+		}
+		service.UnsealKeys = response.Keys
+		service.RootToken = response.RootToken
+		service.client.SetToken(service.RootToken)
+		// make sure we hang on to our keys before we do anything else with them
+		err = service.Write()
+		if err != nil {
+			return err
+		}
+	}
+
+	status, err := service.client.Sys().SealStatus()
+	if err != nil {
+		log.Error().Err(err).Msg("Could not get the seal status of vault")
+		return err
+	}
+	if !status.Sealed {
+		return nil
+	}
+	log.Info().Msg("Unsealing Vault")
+	if len(service.UnsealKeys) == 0 {
+		return service.Unhealthy(terrarium.ReasonSealed, errNoUnsealKeys)
+	}
+	for _, key := range service.UnsealKeys {
+		status, err = service.client.Sys().Unseal(key)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not unseal vault")
+			return err
+		}
+		if !status.Sealed {
+			return nil
+		}
+	}
+	return service.Unhealthy(terrarium.ReasonSealed, errNoUnsealKeys)
+}
+
+// reachable will check that the vault api is answering requests, regardless of whether vault is sealed or initialized
+func (service *Service) reachable() error {
+	_, err := service.client.Sys().Health()
+	if err != nil {
+		return service.Unhealthy(terrarium.ReasonAPIUnreachable, err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package vault
+
+// defaultConfigTemplate is the built in template for the vault server config.  In dev mode vault configures everything
+// we need, so there is nothing here.  Otherwise we set up storage and a listener ourselves.  It can be overridden per
+// project (e.g. to add extra listeners).
+const defaultConfigTemplate = `
+{{- if eq .Vars.storage "dev" -}}
+# vault is running in dev mode with a listener on {{ .Address }}
+{{- else -}}
+{{- if eq .Vars.storage "consul" }}
+storage "consul" {
+  address = "{{ .Vars.consul_address }}"
+  path    = "vault/"
+  token   = "{{ .Vars.consul_token }}"
+}
+{{- else }}
+storage "file" {
+  path = "{{ .Datadir }}/data"
+}
+{{- end }}
+
+listener "tcp" {
+  address         = "127.0.0.1:{{ .Ports.api }}"
+  cluster_address = "127.0.0.1:{{ .Ports.cluster }}"
+  tls_disable     = 1
+}
+
+api_addr      = "{{ .Address }}"
+cluster_addr  = "https://127.0.0.1:{{ .Ports.cluster }}"
+disable_mlock = true
+ui            = true
+{{- end }}
+`
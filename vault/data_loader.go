@@ -1,44 +1,293 @@
 package vault
 
 import (
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/dansteen/terrarium/utility"
 	"github.com/rs/zerolog/log"
-	yaml "gopkg.in/yaml.v2"
 )
 
 // Load will accept a path to a yaml file and will load the content of that file into consul using the methodology described here:
 // https://github.com/traitify/ops_scripts/blob/master/CONSUL_ORGANIZATION.md#app
-func (service *Service) Load(dataFile string) error {
-
-	// make sure the file exists
-	if _, err := os.Stat(dataFile); err != nil {
-		log.Warn().Msg("No data file found. Skipping.")
-		return nil
+// options control which environment is loaded, the values that the file can reference, and the path the secrets are
+// loaded under.  Secrets are only namespaced by application when there is a secret path, so we refuse to prune without
+// one.
+func (service *Service) Load(dataFile string, options utility.DataOptions) error {
+	if options.Prune && options.SecretPath == "" {
+		err := errors.New("secrets can only be pruned when the vault_path_template setting gives each application its own path")
+		log.Error().Err(err).Msg("Could not prune secrets")
+		return err
 	}
-
-	// read the file
-	content, err := ioutil.ReadFile(dataFile)
-	if err != nil {
-		log.Error().Err(err).Msgf("Error reading config file at %s.", dataFile)
+	data, found, err := readDataFile(dataFile, options)
+	if err != nil || !found {
 		return err
 	}
-	// create our data structure
-	data := utility.YamlData{DataType: "vault"}
-	yaml.Unmarshal(content, &data)
 
-	// run through our records and create keys
-	for key, value := range data.Records {
-		_, err = service.client.Logical().Write(filepath.Join("secret", key), map[string]interface{}{"value": value})
+	// run through our secrets and write them
+	secrets := groupSecrets(data)
+	for secretPath, fields := range secrets {
+		mount, relative := service.MountFor(secretPath)
+		_, err = service.client.Logical().Write(mount.DataPath(relative), mount.Wrap(fields))
 		if err != nil {
 			log.Error().Err(err).Msgf("Could not load application secrets file %s to vault:", dataFile)
 			return err
 		}
 	}
 
+	// remove any secrets that are no longer in the file
+	if options.Prune {
+		existing, err := service.secretPaths(data, secrets)
+		if err != nil {
+			return err
+		}
+		for _, secretPath := range existing {
+			if _, ok := secrets[secretPath]; ok {
+				continue
+			}
+			mount, relative := service.MountFor(secretPath)
+			_, err = service.client.Logical().Delete(mount.MetadataPath(relative))
+			if err != nil {
+				log.Error().Err(err).Msgf("Could not remove secret %s from vault:", secretPath)
+				return err
+			}
+			log.Info().Msgf("Removed secret %s from vault", secretPath)
+		}
+	}
+
 	log.Info().Msgf("Loaded data file %s into vault", dataFile)
 	return nil
 }
+
+// Unload will remove the secrets in the provided file from vault
+func (service *Service) Unload(dataFile string, options utility.DataOptions) error {
+	data, found, err := readDataFile(dataFile, options)
+	if err != nil || !found {
+		return err
+	}
+
+	// run through our secrets and remove them
+	for secretPath := range groupSecrets(data) {
+		mount, relative := service.MountFor(secretPath)
+		_, err = service.client.Logical().Delete(mount.MetadataPath(relative))
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not remove application secrets in %s from vault:", dataFile)
+			return err
+		}
+	}
+
+	log.Info().Msgf("Removed secrets in %s from vault", dataFile)
+	return nil
+}
+
+// Diff will compare the secrets in dataFile with the secrets currently in vault and return the keys that differ.  Fields
+// of multi-field secrets are keyed as <secret>/<field>.  We only look for secrets that have been removed from the file
+// under the secret path in options, since without one we can't tell which secrets belong to the application.
+func (service *Service) Diff(dataFile string, options utility.DataOptions) ([]utility.Change, error) {
+	data, found, err := readDataFile(dataFile, options)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		data.Records = map[string]string{}
+	}
+
+	secretPaths, err := service.secretPaths(data, groupSecrets(data))
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]string{}
+	for _, secretPath := range secretPaths {
+		fields, exists, err := service.readSecret(secretPath)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			flattenSecret(current, secretPath, fields, data.Secrets[secretPath])
+		}
+	}
+
+	return utility.Diff(current, data.Records), nil
+}
+
+// Export will return all of the secrets under dir, keyed the same way as the secrets in a secrets file once it has been
+// read.  Each multi-field secret also gets a utility.SecretMarker key so that it can be written out with a !secret tag.
+func (service *Service) Export(dir string) (map[string]string, error) {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return nil, fmt.Errorf("can not export the secrets of every application")
+	}
+	secretPaths, err := service.listSecrets(dir)
+	if err != nil {
+		return nil, err
+	}
+	secrets := map[string]string{}
+	for _, secretPath := range secretPaths {
+		fields, exists, err := service.readSecret(secretPath)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if flattenSecret(secrets, secretPath, fields, false) {
+			secrets[path.Join(secretPath, utility.SecretMarker)] = "true"
+		}
+	}
+	return secrets, nil
+}
+
+// groupSecrets will group the records in data into the secrets they are stored in, keyed by the path of each secret.
+// Records under a map that was tagged !secret are fields of the secret at the key of that map, and every other record
+// is a secret of its own with its value in the value field.
+func groupSecrets(data utility.YamlData) map[string]map[string]interface{} {
+	secrets := map[string]map[string]interface{}{}
+	for key, value := range data.Records {
+		secretPath, field := key, "value"
+		// fields belong to the outermost secret they are in
+		parts := strings.Split(key, "/")
+		for i := 1; i < len(parts); i++ {
+			if data.Secrets[strings.Join(parts[:i], "/")] {
+				secretPath, field = strings.Join(parts[:i], "/"), strings.Join(parts[i:], "/")
+				break
+			}
+		}
+		if _, ok := secrets[secretPath]; !ok {
+			secrets[secretPath] = map[string]interface{}{}
+		}
+		secrets[secretPath][field] = value
+	}
+	return secrets
+}
+
+// flattenSecret will add the fields of the secret at secretPath to records, keyed the same way as groupSecrets expects
+// them.  Secrets that only have a value field are a single record unless multiField is set.  It returns true if the
+// secret was added as multiple fields.
+func flattenSecret(records map[string]string, secretPath string, fields map[string]string, multiField bool) bool {
+	if value, ok := fields["value"]; ok && len(fields) == 1 && !multiField {
+		records[secretPath] = value
+		return false
+	}
+	for field, value := range fields {
+		records[path.Join(secretPath, field)] = value
+	}
+	return true
+}
+
+// secretPaths will return the paths of the secrets in secrets along with the paths of any other secrets under the
+// secret path of data if it has one
+func (service *Service) secretPaths(data utility.YamlData, secrets map[string]map[string]interface{}) ([]string, error) {
+	unique := map[string]bool{}
+	paths := []string{}
+	for secretPath := range secrets {
+		unique[secretPath] = true
+		paths = append(paths, secretPath)
+	}
+	if data.SecretPath != "" {
+		listed, err := service.listSecrets(data.SecretPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, secretPath := range listed {
+			if !unique[secretPath] {
+				unique[secretPath] = true
+				paths = append(paths, secretPath)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// readSecret will read the fields of a single secret from vault.  It returns false if the secret does not exist.
+func (service *Service) readSecret(secretPath string) (map[string]string, bool, error) {
+	mount, relative := service.MountFor(secretPath)
+	secret, err := service.client.Logical().Read(mount.DataPath(relative))
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not read secret %s from vault:", secretPath)
+		return nil, false, err
+	}
+	if secret == nil {
+		return nil, false, nil
+	}
+	values := mount.Unwrap(secret.Data)
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	fields := map[string]string{}
+	for field, value := range values {
+		fields[field] = fmt.Sprintf("%v", value)
+	}
+	return fields, true, nil
+}
+
+// listSecrets will return the keys of all of the secrets under dir
+func (service *Service) listSecrets(dir string) ([]string, error) {
+	mount, relative := service.MountFor(dir + "/")
+	// we never list the whole of a mount since it holds the secrets of every application
+	if strings.Trim(relative, "/") == "" {
+		return []string{}, nil
+	}
+	secret, err := service.client.Logical().List(mount.MetadataPath(relative))
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not list secrets under %s in vault:", dir)
+		return nil, err
+	}
+	keys := []string{}
+	if secret == nil {
+		return keys, nil
+	}
+	entries, _ := secret.Data["keys"].([]interface{})
+	for _, entry := range entries {
+		name := fmt.Sprintf("%v", entry)
+		// directories end in a slash
+		if strings.HasSuffix(name, "/") {
+			children, err := service.listSecrets(path.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, children...)
+			continue
+		}
+		keys = append(keys, path.Join(dir, name))
+	}
+	return keys, nil
+}
+
+// readDataFile will read the secrets in dataFile.  If the file has a default block the block for the environment in
+// options is merged over it, and every key is moved under the secret path in options.  It returns false if there is no
+// data file.
+func readDataFile(dataFile string, options utility.DataOptions) (utility.YamlData, bool, error) {
+	// create our data structure
+	data := utility.YamlData{DataType: "vault", DataOptions: options}
+
+	// make sure the file exists
+	if _, err := os.Stat(dataFile); err != nil {
+		log.Warn().Msg("No data file found. Skipping.")
+		return data, false, nil
+	}
+
+	// read the file
+	err := data.Read(dataFile)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error reading config file at %s.", dataFile)
+		return data, false, err
+	}
+	if options.SecretPath == "" {
+		return data, true, nil
+	}
+	records := map[string]string{}
+	for key, value := range data.Records {
+		records[path.Join(options.SecretPath, key)] = value
+	}
+	secrets := map[string]bool{}
+	for key := range data.Secrets {
+		secrets[path.Join(options.SecretPath, key)] = true
+	}
+	data.Records, data.Secrets = records, secrets
+	return data, true, nil
+}
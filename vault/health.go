@@ -0,0 +1,30 @@
+package vault
+
+import (
+	"context"
+
+	terrarium "github.com/dansteen/terrarium/service"
+)
+
+// Healthy will check the health of the vault instance
+func (service *Service) Healthy(ctx context.Context) (bool, error) {
+	return service.WaitHealthy(ctx, service.probe)
+}
+
+// probe will ask vault if it thinks it is healthy
+func (service *Service) probe() error {
+	health, err := service.client.Sys().Health()
+	if err != nil {
+		return service.Unhealthy(terrarium.ReasonAPIUnreachable, err)
+	}
+	if !health.Initialized {
+		return service.Unhealthy(terrarium.ReasonNotInitialized, nil)
+	}
+	if health.Sealed {
+		return service.Unhealthy(terrarium.ReasonSealed, nil)
+	}
+	if health.Standby {
+		return service.Unhealthy(terrarium.ReasonStandby, nil)
+	}
+	return nil
+}
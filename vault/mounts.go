@@ -0,0 +1,215 @@
+package vault
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/rs/zerolog/log"
+)
+
+// Mount is a secret backend that we mount in vault
+type Mount struct {
+	Path string `yaml:"path" mapstructure:"path"`
+	Type string `yaml:"type" mapstructure:"type"`
+	// the version of the kv backend (1 or 2).  This is ignored for other types.
+	Version int               `yaml:"version,omitempty" mapstructure:"version"`
+	Options map[string]string `yaml:"options,omitempty" mapstructure:"options"`
+}
+
+// DefaultMounts are the mounts we set up if a project doesn't ask for any.  This is a kv v1 backend at secret/ which is
+// what we run in prod.
+var DefaultMounts = []Mount{
+	{Path: "secret/", Type: "kv", Version: 1},
+}
+
+// devMountPath is where dev mode mounts a kv backend
+const devMountPath = "secret/"
+
+// normalize will fill in the defaults for anything that is not set in a mount
+func (mount Mount) normalize() Mount {
+	mount.Path = strings.Trim(mount.Path, "/") + "/"
+	if mount.Type == "" {
+		mount.Type = "kv"
+	}
+	if mount.Type == "kv" && mount.Version == 0 {
+		mount.Version = 1
+	}
+	return mount
+}
+
+// isKV will return true if this mount is a kv backend
+func (mount Mount) isKV() bool {
+	return mount.Type == "kv"
+}
+
+// options will return the options that we pass to vault when we mount or tune this mount
+func (mount Mount) options() map[string]string {
+	options := map[string]string{}
+	for key, value := range mount.Options {
+		options[key] = value
+	}
+	if mount.isKV() {
+		options["version"] = strconv.Itoa(mount.Version)
+	}
+	return options
+}
+
+// DataPath will return the path that the secret at key (relative to the mount) is read and written at
+func (mount Mount) DataPath(key string) string {
+	if mount.isKV() && mount.Version == 2 {
+		return path.Join(mount.Path, "data", key)
+	}
+	return path.Join(mount.Path, key)
+}
+
+// MetadataPath will return the path that the secret at key (relative to the mount) is removed and listed at.  For kv v2
+// this is its metadata, which removes every version of the secret.
+func (mount Mount) MetadataPath(key string) string {
+	if mount.isKV() && mount.Version == 2 {
+		return path.Join(mount.Path, "metadata", key)
+	}
+	return path.Join(mount.Path, key)
+}
+
+// Wrap will put a secret into the format that the mount expects it to be written in
+func (mount Mount) Wrap(secret map[string]interface{}) map[string]interface{} {
+	if mount.isKV() && mount.Version == 2 {
+		return map[string]interface{}{"data": secret}
+	}
+	return secret
+}
+
+// Unwrap will take a secret out of the format that the mount stores it in.  It returns nil if there is no secret (e.g.
+// it has been deleted from a kv v2 mount).
+func (mount Mount) Unwrap(data map[string]interface{}) map[string]interface{} {
+	if mount.isKV() && mount.Version == 2 {
+		secret, _ := data["data"].(map[string]interface{})
+		return secret
+	}
+	return data
+}
+
+// SetMounts will set the secret backends that should be mounted in vault.  If mounts is empty DefaultMounts is used.
+func (service *Service) SetMounts(mounts []Mount) {
+	service.Mounts = []Mount{}
+	for _, mount := range mounts {
+		service.Mounts = append(service.Mounts, mount.normalize())
+	}
+}
+
+// mounts will return the secret backends that should be mounted in vault
+func (service *Service) mounts() []Mount {
+	if len(service.Mounts) == 0 {
+		return DefaultMounts
+	}
+	return service.Mounts
+}
+
+// MountFor will find the mount that key belongs in and return it along with the key relative to that mount.  Keys that
+// start with the path of a mount belong to that mount (the longest one wins), and everything else goes in the first
+// mount.
+func (service *Service) MountFor(key string) (Mount, string) {
+	key = strings.TrimPrefix(key, "/")
+	mounts := service.mounts()
+	found := mounts[0]
+	relative := key
+	matched := 0
+	for _, mount := range mounts {
+		if strings.HasPrefix(key, mount.Path) && len(mount.Path) > matched {
+			found = mount
+			relative = strings.TrimPrefix(key, mount.Path)
+			matched = len(mount.Path)
+		}
+	}
+	return found, relative
+}
+
+// ConfigureBackends will reconcile the secret backends mounted in vault with the ones we have been asked for.  Mounts
+// are created if they are missing and kv backends at the wrong version are upgraded.  Replacing a mount throws its
+// secrets away, so we only do that in dev mode, where they are lost whenever vault stops anyway.  The only mount we
+// remove is the secret/ backend that dev mode creates, and only if we weren't asked for it.
+func (service *Service) ConfigureBackends() error {
+	log.Info().Msg("Configuring secret backends")
+	// TODO: don't know why we need this here. It shouls already be configured when the service is created...
+	service.client.SetToken(service.RootToken)
+
+	existing, err := service.client.Sys().ListMounts()
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not get information on the current secret backends")
+		return err
+	}
+
+	wanted := map[string]bool{}
+	for _, mount := range service.mounts() {
+		wanted[mount.Path] = true
+		err = service.configureMount(mount, existing[mount.Path])
+		if err != nil {
+			return err
+		}
+	}
+
+	// remove the secret/ backend that dev mode creates if it isn't one we want
+	if service.Storage == StorageDev && !wanted[devMountPath] && existing[devMountPath] != nil {
+		log.Info().Msgf("Unmounting %s backend", devMountPath)
+		err = service.client.Sys().Unmount(devMountPath)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not unmount %s backend", devMountPath)
+			return err
+		}
+	}
+	return nil
+}
+
+// configureMount will make sure that a single mount is set up the way we want it.  current is what is mounted at its
+// path now, and is nil if there is nothing there.
+func (service *Service) configureMount(mount Mount, current *vault.MountOutput) error {
+	if current != nil {
+		// if the mount is already what we want there is nothing to do
+		if current.Type == mount.Type && (!mount.isKV() || kvVersion(current) == mount.Version) {
+			return nil
+		}
+		// kv backends can be upgraded from v1 to v2 in place, which keeps their secrets
+		if current.Type == "kv" && mount.isKV() && kvVersion(current) == 1 && mount.Version == 2 {
+			log.Info().Msgf("Upgrading %s backend to kv version 2", mount.Path)
+			err := service.client.Sys().TuneMount(mount.Path, vault.MountConfigInput{Options: mount.options()})
+			if err != nil {
+				log.Error().Err(err).Msgf("Could not upgrade %s backend", mount.Path)
+			}
+			return err
+		}
+		// anything else has to be replaced, which we won't do to secrets that are meant to last
+		if service.Storage != StorageDev {
+			err := fmt.Errorf("%s backend is a %s backend (version %d) and replacing it with a %s backend (version %d) would delete its secrets", mount.Path, current.Type, kvVersion(current), mount.Type, mount.Version)
+			log.Error().Err(err).Msgf("Refusing to replace %s backend. Unmount it by hand or change vault_mounts to match it.", mount.Path)
+			return err
+		}
+		log.Info().Msgf("%s backend is the incorrect type or version. Unmounting.", mount.Path)
+		err := service.client.Sys().Unmount(mount.Path)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not unmount %s backend", mount.Path)
+			return err
+		}
+	}
+
+	log.Info().Msgf("Mounting %s backend", mount.Path)
+	err := service.client.Sys().Mount(mount.Path, &vault.MountInput{
+		Type:    mount.Type,
+		Options: mount.options(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not mount %s backend", mount.Path)
+	}
+	return err
+}
+
+// kvVersion will return the version of a mounted kv backend.  Backends mounted without a version are version 1.
+func kvVersion(mount *vault.MountOutput) int {
+	version, err := strconv.Atoi(mount.Options["version"])
+	if err != nil {
+		return 1
+	}
+	return version
+}
@@ -0,0 +1,224 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dansteen/terrarium/utility"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/rs/zerolog/log"
+)
+
+// NomadServerPolicy is the policy that the token nomad uses to talk to vault is given
+const NomadServerPolicy = "nomad-server"
+
+// reservedPolicies are the policies that vault or terrarium rely on, which applications can't replace
+var reservedPolicies = map[string]bool{"root": true, "default": true, NomadServerPolicy: true}
+
+// NomadRole is the token role that nomad creates the tokens for jobs from
+const NomadRole = "nomad-cluster"
+
+// nomadServerRules are the rules in NomadServerPolicy.  They allow nomad to create tokens for jobs from NomadRole and
+// nothing else (see https://www.nomadproject.io/docs/vault-integration/index.html).
+const nomadServerRules = `
+path "auth/token/create/` + NomadRole + `" {
+  capabilities = ["update"]
+}
+path "auth/token/roles/` + NomadRole + `" {
+  capabilities = ["read"]
+}
+path "auth/token/lookup-self" {
+  capabilities = ["read"]
+}
+path "auth/token/lookup" {
+  capabilities = ["update"]
+}
+path "auth/token/revoke-accessor" {
+  capabilities = ["update"]
+}
+path "sys/capabilities-self" {
+  capabilities = ["update"]
+}
+path "auth/token/renew-self" {
+  capabilities = ["update"]
+}
+`
+
+// ConfigureNomad will set up the policy and token role that nomad needs in order to give jobs their vault tokens the
+// way it does in production, and make sure we have a token for nomad to use.  Tokens that no longer work (e.g. because
+// a dev mode vault was restarted) are replaced.
+func (service *Service) ConfigureNomad() error {
+	log.Info().Msg("Configuring vault for nomad")
+	err := service.client.Sys().PutPolicy(NomadServerPolicy, nomadServerRules)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not write the %s policy", NomadServerPolicy)
+		return err
+	}
+
+	// keep any app policies that have already been allowed
+	allowed, err := service.allowedPolicies()
+	if err != nil {
+		return err
+	}
+	err = service.writeNomadRole(allowed)
+	if err != nil {
+		return err
+	}
+
+	// reuse our existing token if it still works
+	if service.NomadToken != "" {
+		if _, err := service.client.Auth().Token().Lookup(service.NomadToken); err == nil {
+			return nil
+		}
+		log.Info().Msg("Nomad vault token is no longer valid. Replacing.")
+	}
+	renewable := true
+	secret, err := service.client.Auth().Token().CreateOrphan(&vault.TokenCreateRequest{
+		Policies:    []string{NomadServerPolicy},
+		Period:      "72h",
+		Renewable:   &renewable,
+		DisplayName: "nomad",
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not create a vault token for nomad")
+		return err
+	}
+	service.NomadToken = secret.Auth.ClientToken
+	return service.Write()
+}
+
+// LoadPolicies will create the vault policies for an application and allow nomad jobs to use them.  If policyDir has
+// any <name>.hcl files each of them becomes the policy <name>.  Otherwise we generate a policy named after the app that
+// can read each of the secrets in dataFile.  Policies can't be given the name of one of the policies that vault and
+// terrarium rely on.  It returns the names of the policies.
+func (service *Service) LoadPolicies(appName, policyDir, dataFile string, options utility.DataOptions) ([]string, error) {
+	policies := map[string]string{}
+
+	policyFiles, err := filepath.Glob(filepath.Join(policyDir, "*.hcl"))
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not search for policy files in %s:", policyDir)
+		return nil, err
+	}
+	for _, policyFile := range policyFiles {
+		content, err := ioutil.ReadFile(policyFile)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error reading policy file at %s.", policyFile)
+			return nil, err
+		}
+		policies[strings.TrimSuffix(filepath.Base(policyFile), ".hcl")] = string(content)
+	}
+
+	// if the app doesn't bring its own policies we give it read on its own secrets
+	if len(policies) == 0 {
+		rules, err := service.defaultPolicy(dataFile, options)
+		if err != nil {
+			return nil, err
+		}
+		if rules == "" {
+			log.Warn().Msg("No policy files or secrets found. Skipping policies.")
+			return nil, nil
+		}
+		policies[appName] = rules
+	}
+
+	names := []string{}
+	for name := range policies {
+		if reservedPolicies[name] {
+			err = fmt.Errorf("%s is a reserved policy name", name)
+			log.Error().Err(err).Msgf("Could not load the vault policies of %s. Rename the policy.", appName)
+			return nil, err
+		}
+	}
+	for name, rules := range policies {
+		err = service.client.Sys().PutPolicy(name, rules)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not write the %s policy to vault:", name)
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// and let nomad hand them out
+	allowed, err := service.allowedPolicies()
+	if err != nil {
+		return nil, err
+	}
+	err = service.writeNomadRole(append(allowed, names...))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("Loaded vault policies %s", strings.Join(names, ", "))
+	return names, nil
+}
+
+// defaultPolicy will generate the rules for a policy that can read each of the secrets in dataFile.  It returns an empty
+// string if there are no secrets.
+func (service *Service) defaultPolicy(dataFile string, options utility.DataOptions) (string, error) {
+	data, found, err := readDataFile(dataFile, options)
+	if err != nil || !found {
+		return "", err
+	}
+	paths := []string{}
+	for secretPath := range groupSecrets(data) {
+		mount, relative := service.MountFor(secretPath)
+		paths = append(paths, mount.DataPath(relative))
+	}
+	sort.Strings(paths)
+
+	rules := ""
+	for _, path := range paths {
+		rules += fmt.Sprintf("path %q {\n  capabilities = [\"read\"]\n}\n", path)
+	}
+	return rules, nil
+}
+
+// allowedPolicies will return the policies that nomad is currently allowed to give to jobs
+func (service *Service) allowedPolicies() ([]string, error) {
+	allowed := []string{}
+	role, err := service.client.Logical().Read("auth/token/roles/" + NomadRole)
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not read the %s token role", NomadRole)
+		return allowed, err
+	}
+	if role == nil {
+		return allowed, nil
+	}
+	if policies, ok := role.Data["allowed_policies"].([]interface{}); ok {
+		for _, policy := range policies {
+			allowed = append(allowed, fmt.Sprintf("%s", policy))
+		}
+	}
+	return allowed, nil
+}
+
+// writeNomadRole will write the token role that nomad creates job tokens from.  Jobs can only ask for the allowed
+// policies, so a job that asks for a policy that its app doesn't provide fails just as it would in production.
+func (service *Service) writeNomadRole(allowed []string) error {
+	unique := map[string]bool{}
+	policies := []string{}
+	for _, policy := range allowed {
+		if !unique[policy] {
+			unique[policy] = true
+			policies = append(policies, policy)
+		}
+	}
+	sort.Strings(policies)
+
+	_, err := service.client.Logical().Write("auth/token/roles/"+NomadRole, map[string]interface{}{
+		"allowed_policies":    strings.Join(policies, ","),
+		"disallowed_policies": NomadServerPolicy,
+		"orphan":              true,
+		"period":              259200,
+		"renewable":           true,
+		"explicit_max_ttl":    0,
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("Could not write the %s token role", NomadRole)
+	}
+	return err
+}
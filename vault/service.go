@@ -1,14 +1,10 @@
 package vault
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strings"
-	"time"
 
 	yaml "gopkg.in/yaml.v2"
 
@@ -18,38 +14,103 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+// DefaultVersion is the version of vault we run if one is not requested
+const DefaultVersion = "0.10.1"
+
+// the ways that vault can store its data
+const (
+	// StorageDev runs vault in dev mode.  Everything is kept in memory and is lost when vault stops.
+	StorageDev = "dev"
+	// StorageFile keeps vault's data in its data directory
+	StorageFile = "file"
+	// StorageConsul keeps vault's data in the terrarium consul
+	StorageConsul = "consul"
+)
+
 // Service is an instance of this service
 type Service struct {
 	service.Generic
-	RootToken string `yaml:"root_token"`
-	client    *vault.Client
+	RootToken  string   `yaml:"root_token"`
+	Storage    string   `yaml:"storage"`
+	UnsealKeys []string `yaml:"unseal_keys"`
+	Mounts     []Mount  `yaml:"mounts"`
+	// the token that nomad uses to talk to vault
+	NomadToken string `yaml:"nomad_token"`
+	client     *vault.Client
 }
 
-// NewService will create a initialize an instance of the service with default values
-func NewService(workspace string) (*Service, error) {
+// NewService will create a initialize an instance of the service with default values.  If version is empty we keep the
+// version of an existing instance, or use DefaultVersion for a new one.  storage is one of StorageDev, StorageFile or StorageConsul.  If it is empty we keep the
+// storage of an existing instance, or use StorageDev for a new one.  consulAddress and consulToken are only used for
+// StorageConsul.
+func NewService(workspace, version, storage, consulAddress, consulToken string) (*Service, error) {
 	// first initialize the generic stuff
 	newService := Service{}
 	newService.SetName("vault")
 	newService.SetWorkspace(workspace)
-	newService.SetServiceConfig("")
+	// pick up any values from an existing instance in this workspace so that we keep using the same ports and token
+	_, err := newService.Read()
+	if err != nil {
+		return &newService, err
+	}
+	// in dev mode vault always uses the port after its api port for cluster traffic so we need them to be sequential
+	err = newService.AllocateContiguousPorts("api", "cluster")
+	if err != nil {
+		return &newService, err
+	}
+	newService.SetServiceConfig(defaultConfigTemplate)
 	newService.SetHealthyTimeout(30)
-	newService.Version = "0.10.1"
+	newService.SetStopTimeout(10)
+	// if no version is requested we keep running whatever the existing instance runs so that we never downgrade it
+	if version == "" {
+		version = newService.Version
+	}
+	if version == "" {
+		version = DefaultVersion
+	}
+	newService.SetVersion(version)
 	newService.ServiceConfigName = "vault_server.hcl"
-	// hardcoded for now.  We need to change this to support multiple simultanious environments
-	newService.Address = "http://127.0.0.1:8200"
+	newService.Address = fmt.Sprintf("http://127.0.0.1:%d", newService.Port("api"))
 	newService.Datadir = filepath.Join(workspace, newService.Name()+".d")
 	newService.Logfile = filepath.Join(workspace, newService.Name()+".log")
 
-	// generate a root token
-	rootToken, err := uuid.NewV4()
-	if err != nil {
-		log.Error().Err(err).Msg("Could not generate a root token for vault:")
+	// instances recorded before we supported other storage were always in dev mode
+	if newService.Storage == "" {
+		newService.Storage = StorageDev
+	}
+	// if no storage is requested we keep using whatever the existing instance uses so we don't throw its data away
+	if storage == "" {
+		storage = newService.Storage
+	}
+	if storage != StorageDev && storage != StorageFile && storage != StorageConsul {
+		err := fmt.Errorf("unknown vault storage %s", storage)
+		log.Error().Err(err).Msgf("Storage must be one of %s, %s or %s", StorageDev, StorageFile, StorageConsul)
 		return &newService, err
 	}
-	newService.RootToken = rootToken.String()
+	if newService.Storage != storage {
+		newService.RequireRestart(fmt.Sprintf("requested storage %s differs from the running storage %s", storage, newService.Storage))
+	}
+	newService.Storage = storage
+	newService.SetConfigVar("storage", storage)
+	newService.SetConfigVar("consul_address", consulAddress)
+	newService.SetConfigVar("consul_token", consulToken)
+
+	// in dev mode we choose the root token.  Otherwise it is generated when vault is initialized.
+	if newService.RootToken == "" && storage == StorageDev {
+		rootToken, err := uuid.NewV4()
+		if err != nil {
+			log.Error().Err(err).Msg("Could not generate a root token for vault:")
+			return &newService, err
+		}
+		newService.RootToken = rootToken.String()
+	}
+	newService.SetConfigVar("root_token", newService.RootToken)
 
-	newService.Cmdline = fmt.Sprintf("%s server -dev -dev-root-token-id %s &> \"%s\"", filepath.Join(workspace, newService.Name()), newService.RootToken, newService.Logfile)
-	newService.DownloadURL = fmt.Sprintf("https://releases.hashicorp.com/%s/%s/%s_%s_%s_%s.zip", newService.Name(), newService.Version, newService.Name(), newService.Version, runtime.GOOS, runtime.GOARCH)
+	if storage == StorageDev {
+		newService.Cmdline = fmt.Sprintf("%s server -dev -dev-root-token-id %s -dev-listen-address 127.0.0.1:%d -config \"%s\" &> \"%s\"", filepath.Join(workspace, newService.Name()), newService.RootToken, newService.Port("api"), filepath.Join(newService.Datadir, newService.ServiceConfigName), newService.Logfile)
+	} else {
+		newService.Cmdline = fmt.Sprintf("%s server -config \"%s\" &> \"%s\"", filepath.Join(workspace, newService.Name()), filepath.Join(newService.Datadir, newService.ServiceConfigName), newService.Logfile)
+	}
 
 	// set up a client connection
 	client, err := vault.NewClient(&vault.Config{
@@ -71,6 +132,8 @@ func GetService(workspace string) (*Service, error) {
 	service := Service{}
 	service.SetName("vault")
 	service.SetWorkspace(workspace)
+	service.SetHealthyTimeout(30)
+	service.SetStopTimeout(10)
 	found, err := service.Read()
 	if err != nil || !found {
 		log.Error().Err(err).Msgf("Could not get existing %s service in workspace %s", service.Name(), service.Workspace())
@@ -92,67 +155,6 @@ func GetService(workspace string) (*Service, error) {
 	return &service, nil
 }
 
-// Healthy will check the health of the consul instance
-func (service *Service) Healthy() (bool, error) {
-	// first run our generic check
-	healthy, err := service.Generic.Healthy()
-	if err != nil {
-		return healthy, err
-	}
-
-	// then check to see if it thinks its healthy
-	client, err := vault.NewClient(&vault.Config{
-		Address: service.Address,
-	})
-	if err != nil {
-		log.Error().Err(err)
-		return false, err
-	}
-
-	// set up our timer
-	timeout := make(chan bool, 1)
-	go func() {
-		time.Sleep(30 * time.Second)
-		timeout <- false
-	}()
-
-	// keep this up until things return or we timeout
-	for true {
-		select {
-		// we give things 30 seconds to come up
-		case <-timeout:
-			err = fmt.Errorf("Timeout exceeded while starting %s", strings.Title(service.Name()))
-			log.Error().Err(err)
-			return false, err
-		default:
-			// grab our health and return depending on the value
-			health, err := client.Sys().Health()
-			if err == nil {
-				if health.Sealed {
-					err = errors.New("Vault is inexplicably sealed")
-					log.Error().Err(err)
-					return false, err
-				}
-				if health.Standby {
-					err = errors.New("Vault is inexplicably in standby mode")
-					log.Error().Err(err)
-					return false, err
-				}
-				if !health.Initialized {
-					err = errors.New("Vault is not initialized")
-					log.Error().Err(err)
-					return false, err
-				}
-				// if we get here things are good
-				return true, nil
-			}
-			// pause for a second to let things run
-			time.Sleep(1 * time.Second)
-		}
-	}
-	return false, errors.New("We shouldn't be here")
-}
-
 // Read will read an existing instance.  We need to overide the generic reader here to ensure that we get our extra stanzas
 func (service *Service) Read() (bool, error) {
 	// the location of the config file
@@ -190,55 +192,11 @@ func (service *Service) Write() error {
 		log.Error().Err(err).Msg("Error processing config data for writing")
 		return err
 	}
-	// and write it out
-	err = ioutil.WriteFile(configPath, data, 0644)
+	// and write it out.  This holds our root token and unseal keys so only we can read it.
+	err = ioutil.WriteFile(configPath, data, 0600)
 	if err != nil {
 		log.Error().Err(err).Msgf("Error writing config data to %s", configPath)
 		return err
 	}
 	return nil
 }
-
-// ConfigureBackends will configure the backends that we need for vault
-func (service *Service) ConfigureBackends() error {
-	log.Info().Msg("Configuring secret backends")
-	// TODO: don't know why we need this here. It shouls already be configured when the service is created...
-	service.client.SetToken(service.RootToken)
-
-	// newer versions of vault use v2 of the secret backend.  We don't use that in prod (yet) so we reconfigure here
-	// first we check to see what version the secrets are running
-	mounts, err := service.client.Sys().ListMounts()
-	if err != nil {
-		log.Error().Err(err).Msgf("Could not get information on the current secret backend")
-		return err
-	}
-	var hasSecret bool
-	// if the secret mount is not version one we remount, otherwise if it's missing we mount it in the first place
-	if secretMount, hasSecret := mounts["secret/"]; hasSecret {
-		if secretMount.Options["version"] != "1" {
-			log.Info().Msg("Secret/ backend is at incorrect version. Unmounting.")
-			// first unmount what's there
-			err = service.client.Sys().Unmount("secret/")
-			if err != nil {
-				log.Error().Err(err).Msgf("Could not unmount secret/ backend")
-				return err
-			}
-			hasSecret = false
-		}
-	}
-	// if we didn't have a secret mount or we removed it
-	if !hasSecret {
-		log.Info().Msg("Mounting secret/ backend with correct version")
-		// if it has no secret, or we unmountd it because it was the wrong version, we remount it
-		err = service.client.Sys().Mount("secret/",
-			&vault.MountInput{
-				Options: map[string]string{"version": "1", "path": "secret/"},
-				Type:    "kv",
-			})
-		if err != nil {
-			log.Error().Err(err).Msgf("Could not mount secret/ backend")
-			return err
-		}
-	}
-	return nil
-}